@@ -0,0 +1,74 @@
+package amazon
+
+import (
+	"context"
+	"time"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+// Entitlement converts the receipt into a normalized entitlement as of now.
+// For subscriptions cancelDate is the end of the subscription, for the other product types it means the purchase was canceled.
+// The receipt does not contain the price, so PriceMicros and Currency are left empty.
+// https://developer.amazon.com/docs/in-app-purchasing/iap-rvs-for-android-apps.html
+func (r IAPResponse) Entitlement(now time.Time) entitlement.Entitlement {
+	e := entitlement.Entitlement{
+		Store:         entitlement.Amazon,
+		ProductID:     r.ProductID,
+		OriginalID:    r.ReceiptID,
+		TransactionID: r.ReceiptID,
+		PeriodStart:   entitlement.FromMillis(r.PurchaseDate),
+		AutoRenew:     r.AutoRenewing,
+	}
+
+	if r.ProductType != "SUBSCRIPTION" {
+		e.State = entitlement.StateActive
+		if r.CancelDate != 0 {
+			e.Revoked = true
+			e.RevokedAt = entitlement.FromMillis(r.CancelDate)
+			e.State = entitlement.StateRevoked
+		}
+		return e
+	}
+
+	e.PeriodEnd = entitlement.FromMillis(r.RenewalDate)
+	if r.CancelDate != 0 {
+		e.PeriodEnd = entitlement.FromMillis(r.CancelDate)
+	}
+	e.State = entitlement.ExpiredAt(e.PeriodEnd, now)
+	if e.State == entitlement.StateExpired && r.CancelDate == 0 &&
+		r.GracePeriodEndDate != nil && entitlement.FromMillis(*r.GracePeriodEndDate).After(now) {
+		e.State = entitlement.StateGracePeriod
+	}
+	if r.FreeTrialEndDate != nil && entitlement.FromMillis(*r.FreeTrialEndDate).After(now) {
+		e.Trial = true
+	}
+	for _, p := range r.Promotions {
+		if p.PromotionType == IntroductoryPrice && p.PromotionStatus == InProgress {
+			e.IntroOffer = true
+		}
+	}
+	return e
+}
+
+// EntitlementVerifier verifies Amazon receipts as an entitlement.Verifier.
+type EntitlementVerifier struct {
+	client IAPClient
+}
+
+// Verify that EntitlementVerifier implements entitlement.Verifier
+var _ entitlement.Verifier = (*EntitlementVerifier)(nil)
+
+// NewEntitlementVerifier creates a verifier from the client.
+func NewEntitlementVerifier(client IAPClient) *EntitlementVerifier {
+	return &EntitlementVerifier{client: client}
+}
+
+// Verify sends purchase.UserID and the receipt ID in purchase.Token to Amazon, the result contains IAPResponse as Raw.
+func (v *EntitlementVerifier) Verify(ctx context.Context, purchase entitlement.Purchase) (*entitlement.Result, error) {
+	resp, err := v.client.Verify(ctx, purchase.UserID, purchase.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &entitlement.Result{Entitlements: []entitlement.Entitlement{resp.Entitlement(time.Now())}, Raw: resp}, nil
+}
@@ -0,0 +1,94 @@
+package amazon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+func TestIAPResponse_Entitlement(t *testing.T) {
+	t.Parallel()
+	now := time.UnixMilli(1600000000000)
+	trialEnd := int64(1600500000000)
+	graceEnd := int64(1600200000000)
+
+	tests := []struct {
+		name     string
+		receipt  IAPResponse
+		expected entitlement.State
+	}{
+		{
+			name:     "active subscription",
+			receipt:  IAPResponse{ProductType: "SUBSCRIPTION", PurchaseDate: 1599000000000, RenewalDate: 1601000000000, FreeTrialEndDate: &trialEnd},
+			expected: entitlement.StateActive,
+		},
+		{
+			name:     "subscription in grace period",
+			receipt:  IAPResponse{ProductType: "SUBSCRIPTION", PurchaseDate: 1599000000000, RenewalDate: 1599900000000, GracePeriodEndDate: &graceEnd},
+			expected: entitlement.StateGracePeriod,
+		},
+		{
+			name:     "canceled subscription",
+			receipt:  IAPResponse{ProductType: "SUBSCRIPTION", PurchaseDate: 1599000000000, RenewalDate: 1601000000000, CancelDate: 1599500000000},
+			expected: entitlement.StateExpired,
+		},
+		{
+			name:     "entitlement",
+			receipt:  IAPResponse{ProductType: "ENTITLED", PurchaseDate: 1599000000000},
+			expected: entitlement.StateActive,
+		},
+		{
+			name:     "canceled entitlement",
+			receipt:  IAPResponse{ProductType: "ENTITLED", PurchaseDate: 1599000000000, CancelDate: 1599500000000},
+			expected: entitlement.StateRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.receipt.Entitlement(now)
+			if got.State != tt.expected {
+				t.Errorf("got %v, want %v", got.State, tt.expected)
+			}
+			if got.Store != entitlement.Amazon {
+				t.Errorf("got store %v", got.Store)
+			}
+		})
+	}
+
+	got := tests[0].receipt.Entitlement(now)
+	if !got.Trial || !got.PeriodEnd.Equal(time.UnixMilli(1601000000000)) {
+		t.Errorf("unexpected entitlement %+v", got)
+	}
+}
+
+type fakeIAPClient func(ctx context.Context, userID, receiptID string) (IAPResponse, error)
+
+func (f fakeIAPClient) Verify(ctx context.Context, userID, receiptID string) (IAPResponse, error) {
+	return f(ctx, userID, receiptID)
+}
+
+func TestEntitlementVerifier_Verify(t *testing.T) {
+	t.Parallel()
+	client := fakeIAPClient(func(ctx context.Context, userID, receiptID string) (IAPResponse, error) {
+		if userID != "user" || receiptID != "receipt" {
+			return IAPResponse{}, errors.New("unexpected request")
+		}
+		return IAPResponse{ReceiptID: receiptID, ProductID: "gems", ProductType: "CONSUMABLE"}, nil
+	})
+
+	result, err := NewEntitlementVerifier(client).Verify(context.Background(), entitlement.Purchase{
+		Store:  entitlement.Amazon,
+		UserID: "user",
+		Token:  "receipt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entitlements) != 1 || result.Entitlements[0].ProductID != "gems" || !result.Entitlements[0].HasAccess() {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
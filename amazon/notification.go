@@ -14,8 +14,11 @@ const (
 type Notification struct {
 	Type             string `json:"Type"`
 	MessageId        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
 	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
 	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
 	Timestamp        string `json:"Timestamp"`
 	SignatureVersion string `json:"SignatureVersion"`
 	Signature        string `json:"Signature"`
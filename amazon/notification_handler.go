@@ -0,0 +1,172 @@
+package amazon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxNotificationBodySize bounds the request body of an SNS message, which is at most 256 KB.
+const maxNotificationBodySize = 1 << 20
+
+// list of errors returned by NotificationHandler
+var (
+	// ErrNotificationTopicMismatch is returned when an SNS message comes from another topic than the handler's.
+	ErrNotificationTopicMismatch = errors.New("amazon: notification is for another topic")
+	// ErrNotificationTopicMissing is returned when the handler has no TopicArn.
+	ErrNotificationTopicMissing = errors.New("amazon: topic arn of the notification handler is required")
+)
+
+// NotificationHandlerFunc handles a verified notification. Returning an error makes SNS retry it.
+type NotificationHandlerFunc func(ctx context.Context, notification *Notification, message *NotificationMessage) error
+
+// NotificationHandler is an http.Handler for the SNS subscription of the Real-time Notifications topic.
+// It verifies the signature of each SNS message, confirms the subscription, and passes the decoded
+// notifications to Handle.
+// It responds 200 when the message is handled, 400 when it is malformed, 403 when it is not verified
+// or comes from another topic, and 500 when the TopicArn is missing or the confirmation or Handle fails so that SNS retries it.
+// https://developer.amazon.com/docs/in-app-purchasing/rtn-implementation-guide.html
+type NotificationHandler struct {
+	// TopicArn is the ARN of the RTN topic, it is required as any AWS account can publish validly signed messages to its own topic.
+	TopicArn string
+	// Verifier verifies the signatures of the messages, a verifier shared by the handlers when nil.
+	Verifier *SNSVerifier
+	// Handle is called with each notification.
+	Handle NotificationHandlerFunc
+	// Confirm is called with SubscriptionConfirmation messages, ConfirmSubscription when nil.
+	Confirm func(ctx context.Context, notification *Notification) error
+	// Client is used by ConfirmSubscription, a client with a 10 seconds timeout when nil.
+	Client *http.Client
+	// ErrorLog receives the reason a message is rejected or fails, when not nil.
+	ErrorLog func(err error)
+}
+
+// NewNotificationHandler returns a NotificationHandler of the topic topicArn.
+// It returns ErrNotificationTopicMissing when topicArn is empty.
+func NewNotificationHandler(topicArn string, handle NotificationHandlerFunc) (*NotificationHandler, error) {
+	if topicArn == "" {
+		return nil, ErrNotificationTopicMissing
+	}
+	return &NotificationHandler{TopicArn: topicArn, Handle: handle}, nil
+}
+
+var defaultSNSVerifier = NewSNSVerifier()
+
+var defaultConfirmClient = &http.Client{Timeout: 10 * time.Second}
+
+// Decode verifies the SNS message in the request body and checks that it comes from TopicArn.
+func (h *NotificationHandler) Decode(r *http.Request) (*Notification, error) {
+	if h.TopicArn == "" {
+		return nil, ErrNotificationTopicMissing
+	}
+	notification := &Notification{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxNotificationBodySize)).Decode(notification); err != nil {
+		return nil, fmt.Errorf("amazon: failed to decode notification body: %w", err)
+	}
+	if err := h.checkTopic(notification); err != nil {
+		return nil, err
+	}
+	verifier := h.Verifier
+	if verifier == nil {
+		verifier = defaultSNSVerifier
+	}
+	if err := verifier.Verify(r.Context(), notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+func (h *NotificationHandler) checkTopic(notification *Notification) error {
+	if h.TopicArn == "" {
+		return ErrNotificationTopicMissing
+	}
+	if notification.TopicArn != h.TopicArn {
+		return fmt.Errorf("%w: got %q, want %q", ErrNotificationTopicMismatch, notification.TopicArn, h.TopicArn)
+	}
+	return nil
+}
+
+// ConfirmSubscription confirms the subscription of a SubscriptionConfirmation message by visiting its SubscribeURL.
+// Only the subscriptions of TopicArn are confirmed.
+func (h *NotificationHandler) ConfirmSubscription(ctx context.Context, notification *Notification) error {
+	if err := h.checkTopic(notification); err != nil {
+		return err
+	}
+	if err := validateSNSURL(notification.SubscribeURL); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, notification.SubscribeURL, nil)
+	if err != nil {
+		return err
+	}
+	client := h.Client
+	if client == nil {
+		client = defaultConfirmClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("amazon: failed to confirm subscription: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("amazon: failed to confirm subscription: status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	notification, err := h.Decode(r)
+	if err != nil {
+		h.logError(err)
+		if errors.Is(err, ErrNotificationTopicMissing) {
+			w.WriteHeader(http.StatusInternalServerError)
+		} else if errors.Is(err, ErrInvalidSNSSignature) || errors.Is(err, ErrInvalidSNSURL) || errors.Is(err, ErrNotificationTopicMismatch) {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	switch notification.Type {
+	case SNSTypeSubscriptionConfirmation:
+		confirm := h.Confirm
+		if confirm == nil {
+			confirm = h.ConfirmSubscription
+		}
+		err = confirm(r.Context(), notification)
+	case SNSTypeUnsubscribeConfirmation:
+		// the subscription is already deleted, there is nothing left to do
+	case SNSTypeNotification:
+		message := &NotificationMessage{}
+		if err := json.Unmarshal([]byte(notification.Message), message); err != nil {
+			h.logError(fmt.Errorf("amazon: failed to decode notification message: %w", err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if h.Handle != nil {
+			err = h.Handle(r.Context(), notification, message)
+		}
+	}
+	if err != nil {
+		h.logError(fmt.Errorf("amazon: message %s: %w", notification.MessageId, err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *NotificationHandler) logError(err error) {
+	if h.ErrorLog != nil {
+		h.ErrorLog(err)
+	}
+}
@@ -0,0 +1,303 @@
+package amazon
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testTopicArn = "arn:aws:sns:us-east-1:123456789012:rtn"
+	testCertURL  = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-0000.pem"
+)
+
+type testSigner struct {
+	key     *rsa.PrivateKey
+	pem     []byte
+	fetches int32
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (s *testSigner) verifier() *SNSVerifier {
+	return &SNSVerifier{Fetcher: CertFetcherFunc(func(ctx context.Context, url string) ([]byte, error) {
+		atomic.AddInt32(&s.fetches, 1)
+		return s.pem, nil
+	})}
+}
+
+func (s *testSigner) sign(t *testing.T, n *Notification) *Notification {
+	t.Helper()
+	message, err := stringToSign(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := crypto.SHA256
+	var digest []byte
+	if n.SignatureVersion == "1" {
+		hash = crypto.SHA1
+		sum := sha1.Sum(message)
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, hash, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Signature = base64.StdEncoding.EncodeToString(signature)
+	return n
+}
+
+func testNotification(version string) *Notification {
+	return &Notification{
+		Type:             SNSTypeNotification,
+		MessageId:        "message-id",
+		TopicArn:         testTopicArn,
+		Message:          `{"appPackageName":"com.example.app","notificationType":"SUBSCRIPTION_PURCHASED","appUserId":"user","receiptId":"receipt","timestamp":1600000000000}`,
+		Timestamp:        "2024-01-01T00:00:00.000Z",
+		SignatureVersion: version,
+		SigningCertURL:   testCertURL,
+	}
+}
+
+func TestSNSVerifier_Verify(t *testing.T) {
+	t.Parallel()
+	signer := newTestSigner(t)
+	other := newTestSigner(t)
+
+	tests := []struct {
+		name         string
+		notification *Notification
+		wantErr      error
+	}{
+		{name: "version 1", notification: signer.sign(t, testNotification("1"))},
+		{name: "version 2", notification: signer.sign(t, testNotification("2"))},
+		{name: "subject", notification: signer.sign(t, func() *Notification {
+			n := testNotification("2")
+			n.Subject = "subject"
+			return n
+		}())},
+		{name: "subscription confirmation", notification: signer.sign(t, &Notification{
+			Type:             SNSTypeSubscriptionConfirmation,
+			MessageId:        "message-id",
+			Token:            "token",
+			TopicArn:         testTopicArn,
+			Message:          "You have chosen to subscribe to the topic",
+			SubscribeURL:     "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription",
+			Timestamp:        "2024-01-01T00:00:00.000Z",
+			SignatureVersion: "1",
+			SigningCertURL:   testCertURL,
+		})},
+		{name: "tampered message", notification: func() *Notification {
+			n := signer.sign(t, testNotification("2"))
+			n.Message = strings.Replace(n.Message, "user", "attacker", 1)
+			return n
+		}(), wantErr: ErrInvalidSNSSignature},
+		{name: "other key", notification: other.sign(t, testNotification("2")), wantErr: ErrInvalidSNSSignature},
+		{name: "unsupported version", notification: signer.sign(t, testNotification("3")), wantErr: ErrUnsupportedSNSSignatureVersion},
+		{name: "cert host", notification: func() *Notification {
+			n := testNotification("2")
+			n.SigningCertURL = "https://sns.us-east-1.amazonaws.com.example.com/cert.pem"
+			return signer.sign(t, n)
+		}(), wantErr: ErrInvalidSNSURL},
+		{name: "cert scheme", notification: func() *Notification {
+			n := testNotification("2")
+			n.SigningCertURL = "http://sns.us-east-1.amazonaws.com/cert.pem"
+			return signer.sign(t, n)
+		}(), wantErr: ErrInvalidSNSURL},
+		{name: "unsupported type", notification: &Notification{Type: "Unknown", SignatureVersion: "1"}, wantErr: ErrUnsupportedSNSMessageType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := signer.verifier().Verify(context.Background(), tt.notification)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSNSVerifier_CertCache(t *testing.T) {
+	t.Parallel()
+	signer := newTestSigner(t)
+	v := signer.verifier()
+	for i := 0; i < 3; i++ {
+		if err := v.Verify(context.Background(), signer.sign(t, testNotification("2"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if signer.fetches != 1 {
+		t.Errorf("got %d fetches, want 1", signer.fetches)
+	}
+
+	v.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if err := v.Verify(context.Background(), signer.sign(t, testNotification("2"))); !errors.Is(err, ErrInvalidSNSSignature) {
+		t.Errorf("got %v, want %v", err, ErrInvalidSNSSignature)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestNotificationHandler(t *testing.T) {
+	t.Parallel()
+	signer := newTestSigner(t)
+	body := func(n *Notification) string {
+		b, err := json.Marshal(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	confirmationOf := func(topicArn, subscribeURL string) *Notification {
+		return signer.sign(t, &Notification{
+			Type:             SNSTypeSubscriptionConfirmation,
+			MessageId:        "message-id",
+			Token:            "token",
+			TopicArn:         topicArn,
+			Message:          "You have chosen to subscribe to the topic",
+			SubscribeURL:     subscribeURL,
+			Timestamp:        "2024-01-01T00:00:00.000Z",
+			SignatureVersion: "2",
+			SigningCertURL:   testCertURL,
+		})
+	}
+	confirmation := func(subscribeURL string) *Notification {
+		return confirmationOf(testTopicArn, subscribeURL)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		noTopic       bool
+		body          string
+		handleErr     error
+		wantStatus    int
+		wantCalled    bool
+		wantConfirmed bool
+	}{
+		{name: "notification", body: body(signer.sign(t, testNotification("2"))), wantStatus: http.StatusOK, wantCalled: true},
+		{name: "handler error", body: body(signer.sign(t, testNotification("1"))), handleErr: errors.New("db down"), wantStatus: http.StatusInternalServerError, wantCalled: true},
+		{name: "subscription confirmation", body: body(confirmation("https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=token")), wantStatus: http.StatusOK, wantConfirmed: true},
+		{name: "subscription confirmation of other topic", body: body(confirmationOf("arn:aws:sns:us-east-1:210987654321:attacker", "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=token")), wantStatus: http.StatusForbidden},
+		{name: "missing topic", noTopic: true, body: body(confirmation("https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=token")), wantStatus: http.StatusInternalServerError},
+		{name: "subscribe url host", body: body(confirmation("https://example.com/?Action=ConfirmSubscription")), wantStatus: http.StatusInternalServerError},
+		{name: "unsubscribe confirmation", body: body(func() *Notification {
+			n := confirmation("https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=token")
+			n.Type = SNSTypeUnsubscribeConfirmation
+			return signer.sign(t, n)
+		}()), wantStatus: http.StatusOK},
+		{name: "forged", body: body(func() *Notification {
+			n := signer.sign(t, testNotification("2"))
+			n.Message = strings.Replace(n.Message, "user", "attacker", 1)
+			return n
+		}()), wantStatus: http.StatusForbidden},
+		{name: "other topic", body: body(func() *Notification {
+			n := testNotification("2")
+			n.TopicArn = "arn:aws:sns:us-east-1:123456789012:other"
+			return signer.sign(t, n)
+		}()), wantStatus: http.StatusForbidden},
+		{name: "malformed message", body: body(func() *Notification {
+			n := testNotification("2")
+			n.Message = "not json"
+			return signer.sign(t, n)
+		}()), wantStatus: http.StatusBadRequest},
+		{name: "malformed body", body: "{", wantStatus: http.StatusBadRequest},
+		{name: "method", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called, confirmed bool
+			h, err := NewNotificationHandler(testTopicArn, func(ctx context.Context, n *Notification, m *NotificationMessage) error {
+				called = true
+				if m.NotificationType != NotificationTypeSubscription || m.ReceiptId != "receipt" {
+					t.Errorf("unexpected message %+v", m)
+				}
+				return tt.handleErr
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.noTopic {
+				// as a handler built as a literal without TopicArn
+				h.TopicArn = ""
+			}
+			h.Verifier = signer.verifier()
+			h.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				confirmed = r.URL.Query().Get("Token") == "token"
+				rec := httptest.NewRecorder()
+				rec.WriteHeader(http.StatusOK)
+				return rec.Result(), nil
+			})}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(method, "/rtn", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("got called %v, want %v", called, tt.wantCalled)
+			}
+			if confirmed != tt.wantConfirmed {
+				t.Errorf("got confirmed %v, want %v", confirmed, tt.wantConfirmed)
+			}
+		})
+	}
+}
+
+func TestNewNotificationHandler_TopicMissing(t *testing.T) {
+	t.Parallel()
+	if _, err := NewNotificationHandler("", nil); !errors.Is(err, ErrNotificationTopicMissing) {
+		t.Errorf("got %v, want %v", err, ErrNotificationTopicMissing)
+	}
+	h := &NotificationHandler{}
+	if err := h.ConfirmSubscription(context.Background(), &Notification{TopicArn: testTopicArn}); !errors.Is(err, ErrNotificationTopicMissing) {
+		t.Errorf("got %v, want %v", err, ErrNotificationTopicMissing)
+	}
+}
@@ -0,0 +1,236 @@
+package amazon
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SNS message types delivered to the RTN endpoint.
+// https://docs.aws.amazon.com/sns/latest/dg/sns-message-and-json-formats.html
+const (
+	SNSTypeNotification             = "Notification"
+	SNSTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	SNSTypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// list of errors returned while verifying an SNS message
+var (
+	ErrInvalidSNSURL                  = errors.New("amazon: SNS URL is not an https URL of sns.*.amazonaws.com")
+	ErrUnsupportedSNSMessageType      = errors.New("amazon: unsupported SNS message type")
+	ErrUnsupportedSNSSignatureVersion = errors.New("amazon: unsupported SNS signature version")
+	ErrInvalidSNSSignature            = errors.New("amazon: invalid SNS signature")
+)
+
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// validateSNSURL checks that rawURL is an https URL of an SNS endpoint.
+func validateSNSURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Port() != "" || !snsHostPattern.MatchString(u.Hostname()) {
+		return fmt.Errorf("%w: %q", ErrInvalidSNSURL, rawURL)
+	}
+	return nil
+}
+
+// CertFetcher fetches the PEM encoded signing certificate of an SNS message.
+type CertFetcher interface {
+	FetchCert(ctx context.Context, url string) ([]byte, error)
+}
+
+// CertFetcherFunc is a function implementing CertFetcher.
+type CertFetcherFunc func(ctx context.Context, url string) ([]byte, error)
+
+// FetchCert implements CertFetcher.
+func (f CertFetcherFunc) FetchCert(ctx context.Context, url string) ([]byte, error) {
+	return f(ctx, url)
+}
+
+// HTTPCertFetcher fetches the signing certificates over HTTP.
+type HTTPCertFetcher struct {
+	Client *http.Client
+}
+
+// FetchCert implements CertFetcher.
+func (f *HTTPCertFetcher) FetchCert(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("amazon: failed to fetch SNS signing certificate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("amazon: failed to fetch SNS signing certificate: status code %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// SNSVerifier verifies the signatures of the SNS messages of the Real-time Notifications.
+// The signing certificates are cached by URL until they expire.
+// https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html
+type SNSVerifier struct {
+	// Fetcher fetches the signing certificates, an HTTPCertFetcher when nil.
+	Fetcher CertFetcher
+	// Now returns the current time to check the certificate validity, time.Now when nil.
+	Now func() time.Time
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+// NewSNSVerifier returns an SNSVerifier fetching the signing certificates with a 10 seconds timeout.
+func NewSNSVerifier() *SNSVerifier {
+	return &SNSVerifier{Fetcher: &HTTPCertFetcher{Client: &http.Client{Timeout: 10 * time.Second}}}
+}
+
+// Verify verifies the signature of the SNS message n.
+func (v *SNSVerifier) Verify(ctx context.Context, n *Notification) error {
+	var hash crypto.Hash
+	switch n.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedSNSSignatureVersion, n.SignatureVersion)
+	}
+	message, err := stringToSign(n)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(n.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSNSSignature, err)
+	}
+	cert, err := v.cert(ctx, n.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: signing certificate has no RSA key", ErrInvalidSNSSignature)
+	}
+
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum(message)
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSNSSignature, err)
+	}
+	return nil
+}
+
+func (v *SNSVerifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// cert returns the signing certificate at rawURL, from the cache when it is still valid.
+func (v *SNSVerifier) cert(ctx context.Context, rawURL string) (*x509.Certificate, error) {
+	if err := validateSNSURL(rawURL); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(rawURL, ".pem") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSNSURL, rawURL)
+	}
+
+	now := v.now()
+	v.mu.Lock()
+	cert, ok := v.certs[rawURL]
+	v.mu.Unlock()
+	if ok && now.Before(cert.NotAfter) {
+		return cert, nil
+	}
+
+	// the certificate is fetched without the lock, concurrent misses may fetch it more than once
+
+	fetcher := v.Fetcher
+	if fetcher == nil {
+		fetcher = &HTTPCertFetcher{}
+	}
+	data, err := fetcher.FetchCert(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("amazon: SNS signing certificate is not PEM encoded")
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("amazon: failed to parse SNS signing certificate: %w", err)
+	}
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("%w: signing certificate is not valid at %s", ErrInvalidSNSSignature, now.Format(time.RFC3339))
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.certs == nil {
+		v.certs = map[string]*x509.Certificate{}
+	}
+	v.certs[rawURL] = cert
+	return cert, nil
+}
+
+// stringToSign builds the canonical string an SNS message is signed over.
+func stringToSign(n *Notification) ([]byte, error) {
+	var fields [][2]string
+	switch n.Type {
+	case SNSTypeNotification:
+		fields = [][2]string{{"Message", n.Message}, {"MessageId", n.MessageId}}
+		if n.Subject != "" {
+			fields = append(fields, [2]string{"Subject", n.Subject})
+		}
+		fields = append(fields, [2]string{"Timestamp", n.Timestamp}, [2]string{"TopicArn", n.TopicArn}, [2]string{"Type", n.Type})
+	case SNSTypeSubscriptionConfirmation, SNSTypeUnsubscribeConfirmation:
+		fields = [][2]string{
+			{"Message", n.Message},
+			{"MessageId", n.MessageId},
+			{"SubscribeURL", n.SubscribeURL},
+			{"Timestamp", n.Timestamp},
+			{"Token", n.Token},
+			{"TopicArn", n.TopicArn},
+			{"Type", n.Type},
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedSNSMessageType, n.Type)
+	}
+
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f[0])
+		b.WriteByte('\n')
+		b.WriteString(f[1])
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// list of errors returned by VerifyAppTransaction
+var (
+	ErrNotAppTransaction                = errors.New("appstore api: not an app transaction")
+	ErrAppTransactionBundleIDMismatch   = errors.New("appstore api: app transaction bundleId mismatch")
+	ErrAppTransactionAppAppleIDMismatch = errors.New("appstore api: app transaction appAppleId mismatch")
+	ErrAppTransactionEnvironment        = errors.New("appstore api: app transaction environment mismatch")
+)
+
+// Verify that AppTransaction implements jwt.Claims
+var _ jwt.Claims = AppTransaction{}
+
+// AppTransaction https://developer.apple.com/documentation/appstoreserverapi/jwsapptransactiondecodedpayload
+type AppTransaction struct {
+	AppAppleId                 int64       `json:"appAppleId,omitempty"`
+	AppTransactionId           string      `json:"appTransactionId,omitempty"`
+	ApplicationVersion         string      `json:"applicationVersion"`
+	BundleId                   string      `json:"bundleId"`
+	DeviceVerification         string      `json:"deviceVerification"`
+	DeviceVerificationNonce    string      `json:"deviceVerificationNonce"`
+	OriginalApplicationVersion string      `json:"originalApplicationVersion"`
+	OriginalPlatform           string      `json:"originalPlatform,omitempty"`
+	OriginalPurchaseDate       int64       `json:"originalPurchaseDate"`
+	PreorderDate               int64       `json:"preorderDate,omitempty"`
+	ReceiptCreationDate        int64       `json:"receiptCreationDate"`
+	ReceiptType                Environment `json:"receiptType"`
+	SignedDate                 int64       `json:"signedDate"`
+	VersionExternalIdentifier  int64       `json:"versionExternalIdentifier,omitempty"`
+}
+
+// GetAudience implements jwt.Claims.
+func (J AppTransaction) GetAudience() (jwt.ClaimStrings, error) {
+	return nil, nil
+}
+
+// GetExpirationTime implements jwt.Claims.
+func (J AppTransaction) GetExpirationTime() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+// GetIssuedAt implements jwt.Claims.
+func (J AppTransaction) GetIssuedAt() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+// GetIssuer implements jwt.Claims.
+func (J AppTransaction) GetIssuer() (string, error) {
+	return "", nil
+}
+
+// GetNotBefore implements jwt.Claims.
+func (J AppTransaction) GetNotBefore() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+// GetSubject implements jwt.Claims.
+func (J AppTransaction) GetSubject() (string, error) {
+	return "", nil
+}
+
+// VerifyAppTransaction verifies the signed AppTransaction sent by StoreKit 2, the proof that a user purchased the app.
+// Besides the certificate chain it checks that the bundleId is the BundleID of the StoreConfig and the receiptType is its environment.
+// appAppleId is required in Production, where it must match the app transaction. Sandbox app transactions have no appAppleId, so it is only checked when not zero.
+// ctx is used by the revocation check of the certificate chain.
+// https://developer.apple.com/documentation/storekit/apptransaction
+func (a *StoreClient) VerifyAppTransaction(ctx context.Context, signedAppTransaction string, appAppleId int64) (*AppTransaction, error) {
+	payloadType, err := DetectJWSPayloadType(signedAppTransaction)
+	if err != nil {
+		return nil, err
+	}
+	if payloadType != JWSPayloadAppTransaction {
+		return nil, fmt.Errorf("%w: got %v", ErrNotAppTransaction, payloadType)
+	}
+
+	appTransaction := &AppTransaction{}
+	if err := a.parseJWS(ctx, signedAppTransaction, appTransaction); err != nil {
+		return nil, err
+	}
+
+	if appTransaction.BundleId != a.Token.BundleID {
+		return nil, fmt.Errorf("%w: got %q, want %q", ErrAppTransactionBundleIDMismatch, appTransaction.BundleId, a.Token.BundleID)
+	}
+	environment := Production
+	if a.Token.Sandbox {
+		environment = Sandbox
+	}
+	if appTransaction.ReceiptType != environment {
+		return nil, fmt.Errorf("%w: got %v, want %v", ErrAppTransactionEnvironment, appTransaction.ReceiptType, environment)
+	}
+	if (environment == Production || appAppleId != 0) && appTransaction.AppAppleId != appAppleId {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrAppTransactionAppAppleIDMismatch, appTransaction.AppAppleId, appAppleId)
+	}
+	return appTransaction, nil
+}
@@ -0,0 +1,93 @@
+package api_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/apitest"
+)
+
+func TestStoreClient_VerifyAppTransaction(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	s.Environment = api.Production
+	client := s.Client()
+
+	tests := []struct {
+		name       string
+		claims     jwt.Claims
+		appAppleId int64
+		wantErr    error
+	}{
+		{name: "valid", claims: api.AppTransaction{BundleId: "com.example.app", AppAppleId: 1234, ReceiptType: api.Production, OriginalApplicationVersion: "1.0"}, appAppleId: 1234},
+		{name: "bundle id", claims: api.AppTransaction{BundleId: "com.example.other", AppAppleId: 1234, ReceiptType: api.Production}, appAppleId: 1234, wantErr: api.ErrAppTransactionBundleIDMismatch},
+		{name: "app apple id", claims: api.AppTransaction{BundleId: "com.example.app", AppAppleId: 5678, ReceiptType: api.Production}, appAppleId: 1234, wantErr: api.ErrAppTransactionAppAppleIDMismatch},
+		{name: "missing app apple id", claims: api.AppTransaction{BundleId: "com.example.app", AppAppleId: 1234, ReceiptType: api.Production}, wantErr: api.ErrAppTransactionAppAppleIDMismatch},
+		{name: "environment", claims: api.AppTransaction{BundleId: "com.example.app", ReceiptType: api.Sandbox}, appAppleId: 1234, wantErr: api.ErrAppTransactionEnvironment},
+		{name: "transaction", claims: api.JWSTransaction{TransactionID: "1001", BundleID: "com.example.app"}, appAppleId: 1234, wantErr: api.ErrNotAppTransaction},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := s.Sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := client.VerifyAppTransaction(context.Background(), signed, tt.appAppleId)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.OriginalApplicationVersion != "1.0" {
+				t.Errorf("got %v, want 1.0", got.OriginalApplicationVersion)
+			}
+		})
+	}
+}
+
+func TestStoreClient_VerifyAppTransaction_Sandbox(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	signed, err := s.Sign(api.AppTransaction{BundleId: "com.example.app", ReceiptType: api.Sandbox})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Client().VerifyAppTransaction(context.Background(), signed, 0); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
+
+func TestStoreClient_VerifyAppTransaction_MalformedX5c(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"bundleId":"com.example.app","receiptType":"Sandbox"}`))
+
+	tests := map[string]string{
+		"empty x5c": `{"alg":"ES256","x5c":[]}`,
+		"short x5c": `{"alg":"ES256","x5c":["AAAA","AAAA"]}`,
+	}
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + payload + ".sig"
+			if _, err := s.Client().VerifyAppTransaction(context.Background(), signed, 0); !errors.Is(err, api.ErrMalformedJWS) {
+				t.Errorf("got %v, want %v", err, api.ErrMalformedJWS)
+			}
+		})
+	}
+}
@@ -1,33 +1,30 @@
 package api
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
-)
+	"time"
 
-// rootPEM is generated through `openssl x509 -inform der -in AppleRootCA-G3.cer -out apple_root.pem`
-const rootPEM = `
------BEGIN CERTIFICATE-----
-MIICQzCCAcmgAwIBAgIILcX8iNLFS5UwCgYIKoZIzj0EAwMwZzEbMBkGA1UEAwwS
-QXBwbGUgUm9vdCBDQSAtIEczMSYwJAYDVQQLDB1BcHBsZSBDZXJ0aWZpY2F0aW9u
-IEF1dGhvcml0eTETMBEGA1UECgwKQXBwbGUgSW5jLjELMAkGA1UEBhMCVVMwHhcN
-MTQwNDMwMTgxOTA2WhcNMzkwNDMwMTgxOTA2WjBnMRswGQYDVQQDDBJBcHBsZSBS
-b290IENBIC0gRzMxJjAkBgNVBAsMHUFwcGxlIENlcnRpZmljYXRpb24gQXV0aG9y
-aXR5MRMwEQYDVQQKDApBcHBsZSBJbmMuMQswCQYDVQQGEwJVUzB2MBAGByqGSM49
-AgEGBSuBBAAiA2IABJjpLz1AcqTtkyJygRMc3RCV8cWjTnHcFBbZDuWmBSp3ZHtf
-TjjTuxxEtX/1H7YyYl3J6YRbTzBPEVoA/VhYDKX1DyxNB0cTddqXl5dvMVztK517
-IDvYuVTZXpmkOlEKMaNCMEAwHQYDVR0OBBYEFLuw3qFYM4iapIqZ3r6966/ayySr
-MA8GA1UdEwEB/wQFMAMBAf8wDgYDVR0PAQH/BAQDAgEGMAoGCCqGSM49BAMDA2gA
-MGUCMQCD6cHEFl4aXTQY2e3v9GwOAEZLuN+yRhHFD/3meoyhpmvOwgPUnPWTxnS4
-at+qIxUCMG1mihDK1A3UT82NQz60imOlM27jbdoXt2QfyFMm+YhidDkLF1vLUagM
-6BgD56KyKA==
------END CERTIFICATE-----
-`
+	"github.com/awa/go-iap/appstore/chain"
+)
 
 type Cert struct {
+	// Verifier verifies the certificate chains. When nil, the Apple Root CA - G3 is trusted and revocation is not checked.
+	Verifier *chain.Verifier
+}
+
+var defaultVerifier = &chain.Verifier{}
+
+func (c *Cert) verifier() *chain.Verifier {
+	if c.Verifier != nil {
+		return c.Verifier
+	}
+	return defaultVerifier
 }
 
 func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
@@ -35,6 +32,23 @@ func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
 		return nil, errors.New("invalid index")
 	}
 
+	x5c, err := c.extractX5c(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if len(x5c) <= index {
+		return nil, errors.New("failed to extract cert from x5c header, possible unauthorised request detected")
+	}
+	certByte, err := base64.StdEncoding.DecodeString(x5c[index])
+	if err != nil {
+		return nil, err
+	}
+
+	return certByte, nil
+}
+
+// extractX5c returns the x5c header of the token.
+func (c *Cert) extractX5c(tokenStr string) ([]string, error) {
 	tokenArr := strings.Split(tokenStr, ".")
 	headerByte, err := base64.RawStdEncoding.DecodeString(tokenArr[0])
 	if err != nil {
@@ -50,38 +64,26 @@ func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	certByte, err := base64.StdEncoding.DecodeString(header.X5c[index])
-	if err != nil {
-		return nil, err
-	}
-
-	return certByte, nil
+	return header.X5c, nil
 }
 
-func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate) error {
-	roots := x509.NewCertPool()
-	ok := roots.AppendCertsFromPEM([]byte(rootPEM))
-	if !ok {
-		return errors.New("failed to parse root certificate")
-	}
-
-	intermedia := x509.NewCertPool()
-	intermedia.AddCert(intermediaCert)
-
-	opts := x509.VerifyOptions{
-		Roots:         roots,
-		Intermediates: intermedia,
-	}
-	_, err := rootCert.Verify(opts)
+// checkX5c checks that the x5c header of the token has the leaf, intermediate and root certificates.
+func (c *Cert) checkX5c(tokenStr string) error {
+	x5c, err := c.extractX5c(tokenStr)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrMalformedJWS, err)
+	}
+	if len(x5c) != 3 {
+		return fmt.Errorf("%w: got %d x5c certificates, want 3", ErrMalformedJWS, len(x5c))
 	}
+	return nil
+}
 
-	_, err = leafCert.Verify(opts)
+// verifyCert verifies the certificate chain, ctx is used by the revocation check.
+func (c *Cert) verifyCert(ctx context.Context, rootCert, intermediaCert, leafCert *x509.Certificate, signedDate time.Time) error {
+	at, err := c.verifier().VerificationTime(signedDate)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return c.verifier().VerifyAt(ctx, []*x509.Certificate{leafCert, intermediaCert, rootCert}, at)
 }
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// DecodedTransaction is a signed transaction verified and decoded by the Decoded methods.
+// Transaction is nil and Err is set when the transaction fails verification.
+type DecodedTransaction struct {
+	Signed      string
+	Transaction *JWSTransaction
+	Err         error
+}
+
+// DecodedRenewalInfo is a signed renewal info verified and decoded by the Decoded methods.
+// RenewalInfo is nil and Err is set when the renewal info fails verification.
+type DecodedRenewalInfo struct {
+	Signed      string
+	RenewalInfo *JWSRenewalInfoDecodedPayload
+	Err         error
+}
+
+// DecodedHistoryResponse is a HistoryResponse with its transactions decoded.
+type DecodedHistoryResponse struct {
+	AppAppleId   int64
+	BundleId     string
+	Environment  Environment
+	HasMore      bool
+	Revision     string
+	Transactions []DecodedTransaction
+}
+
+// DecodedRefundLookupResponse is a RefundLookupResponse with its transactions decoded.
+type DecodedRefundLookupResponse struct {
+	HasMore      bool
+	Revision     string
+	Transactions []DecodedTransaction
+}
+
+// DecodedOrderLookupResponse is an OrderLookupResponse with its transactions decoded.
+type DecodedOrderLookupResponse struct {
+	Status       int
+	Environment  Environment
+	Transactions []DecodedTransaction
+}
+
+// DecodedStatusResponse is a StatusResponse with its transactions and renewal infos decoded.
+type DecodedStatusResponse struct {
+	Environment Environment
+	AppAppleId  int64
+	BundleId    string
+	Data        []DecodedSubscriptionGroupIdentifierItem
+}
+
+// DecodedSubscriptionGroupIdentifierItem is a SubscriptionGroupIdentifierItem with its last transactions decoded.
+type DecodedSubscriptionGroupIdentifierItem struct {
+	SubscriptionGroupIdentifier string
+	LastTransactions            []DecodedLastTransactionsItem
+}
+
+// DecodedLastTransactionsItem is a LastTransactionsItem with its transaction and renewal info decoded.
+type DecodedLastTransactionsItem struct {
+	OriginalTransactionId string
+	Status                AutoRenewSubscriptionStatus
+	Transaction           DecodedTransaction
+	RenewalInfo           DecodedRenewalInfo
+}
+
+// Err joins the verification errors of the transactions, nil when all of them are verified.
+func (r *DecodedHistoryResponse) Err() error {
+	return transactionsErr(r.Transactions)
+}
+
+// Err joins the verification errors of the transactions, nil when all of them are verified.
+func (r *DecodedRefundLookupResponse) Err() error {
+	return transactionsErr(r.Transactions)
+}
+
+// Err joins the verification errors of the transactions, nil when all of them are verified.
+func (r *DecodedOrderLookupResponse) Err() error {
+	return transactionsErr(r.Transactions)
+}
+
+// Err joins the verification errors of the transactions and renewal infos, nil when all of them are verified.
+func (r *DecodedStatusResponse) Err() error {
+	var errs []error
+	for _, group := range r.Data {
+		for _, item := range group.LastTransactions {
+			errs = append(errs, item.Transaction.Err, item.RenewalInfo.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func transactionsErr(transactions []DecodedTransaction) error {
+	var errs []error
+	for _, t := range transactions {
+		errs = append(errs, t.Err)
+	}
+	return errors.Join(errs...)
+}
+
+// ParseSignedRenewalInfo verifies and decodes a signed renewal info.
+func (a *StoreClient) ParseSignedRenewalInfo(renewalInfo string) (*JWSRenewalInfoDecodedPayload, error) {
+	return a.parseSignedRenewalInfo(context.Background(), renewalInfo)
+}
+
+func (a *StoreClient) parseSignedRenewalInfo(ctx context.Context, renewalInfo string) (*JWSRenewalInfoDecodedPayload, error) {
+	info := &JWSRenewalInfoDecodedPayload{}
+	if err := a.parseJWS(ctx, renewalInfo, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// decodeTransactions verifies and decodes every signed transaction, keeping the failures with their error.
+func (a *StoreClient) decodeTransactions(ctx context.Context, signed []string) []DecodedTransaction {
+	decoded := make([]DecodedTransaction, 0, len(signed))
+	for _, s := range signed {
+		tx, err := a.parseSignedTransaction(ctx, s)
+		decoded = append(decoded, DecodedTransaction{Signed: s, Transaction: tx, Err: err})
+	}
+	return decoded
+}
+
+// GetTransactionHistoryDecoded is GetTransactionHistory with the transactions verified and decoded.
+// The error is only about the requests, the verification errors are reported by each transaction.
+func (a *StoreClient) GetTransactionHistoryDecoded(ctx context.Context, transactionId string, query *url.Values) ([]*DecodedHistoryResponse, error) {
+	responses, err := a.GetTransactionHistory(ctx, transactionId, query)
+	if err != nil {
+		return nil, err
+	}
+	decoded := make([]*DecodedHistoryResponse, 0, len(responses))
+	for _, rsp := range responses {
+		decoded = append(decoded, &DecodedHistoryResponse{
+			AppAppleId:   rsp.AppAppleId,
+			BundleId:     rsp.BundleId,
+			Environment:  rsp.Environment,
+			HasMore:      rsp.HasMore,
+			Revision:     rsp.Revision,
+			Transactions: a.decodeTransactions(ctx, rsp.SignedTransactions),
+		})
+	}
+	return decoded, nil
+}
+
+// GetRefundHistoryDecoded is GetRefundHistory with the transactions verified and decoded.
+// The error is only about the requests, the verification errors are reported by each transaction.
+func (a *StoreClient) GetRefundHistoryDecoded(ctx context.Context, originalTransactionId string) ([]*DecodedRefundLookupResponse, error) {
+	responses, err := a.GetRefundHistory(ctx, originalTransactionId)
+	if err != nil {
+		return nil, err
+	}
+	decoded := make([]*DecodedRefundLookupResponse, 0, len(responses))
+	for _, rsp := range responses {
+		decoded = append(decoded, &DecodedRefundLookupResponse{
+			HasMore:      rsp.HasMore,
+			Revision:     rsp.Revision,
+			Transactions: a.decodeTransactions(ctx, rsp.SignedTransactions),
+		})
+	}
+	return decoded, nil
+}
+
+// LookupOrderIDDecoded is LookupOrderID with the transactions verified and decoded.
+// The error is only about the request, the verification errors are reported by each transaction.
+func (a *StoreClient) LookupOrderIDDecoded(ctx context.Context, orderId string) (*DecodedOrderLookupResponse, error) {
+	rsp, err := a.LookupOrderID(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedOrderLookupResponse{
+		Status:       rsp.Status,
+		Environment:  rsp.Environment,
+		Transactions: a.decodeTransactions(ctx, rsp.SignedTransactions),
+	}, nil
+}
+
+// GetALLSubscriptionStatusesDecoded is GetALLSubscriptionStatuses with the transactions and renewal infos verified and decoded.
+// The error is only about the request, the verification errors are reported by each item.
+func (a *StoreClient) GetALLSubscriptionStatusesDecoded(ctx context.Context, originalTransactionId string, query *url.Values) (*DecodedStatusResponse, error) {
+	rsp, err := a.GetALLSubscriptionStatuses(ctx, originalTransactionId, query)
+	if err != nil {
+		return nil, err
+	}
+	decoded := &DecodedStatusResponse{
+		Environment: rsp.Environment,
+		AppAppleId:  rsp.AppAppleId,
+		BundleId:    rsp.BundleId,
+		Data:        make([]DecodedSubscriptionGroupIdentifierItem, 0, len(rsp.Data)),
+	}
+	for _, group := range rsp.Data {
+		item := DecodedSubscriptionGroupIdentifierItem{
+			SubscriptionGroupIdentifier: group.SubscriptionGroupIdentifier,
+			LastTransactions:            make([]DecodedLastTransactionsItem, 0, len(group.LastTransactions)),
+		}
+		for _, last := range group.LastTransactions {
+			tx, txErr := a.parseSignedTransaction(ctx, last.SignedTransactionInfo)
+			info, infoErr := a.parseSignedRenewalInfo(ctx, last.SignedRenewalInfo)
+			item.LastTransactions = append(item.LastTransactions, DecodedLastTransactionsItem{
+				OriginalTransactionId: last.OriginalTransactionId,
+				Status:                last.Status,
+				Transaction:           DecodedTransaction{Signed: last.SignedTransactionInfo, Transaction: tx, Err: txErr},
+				RenewalInfo:           DecodedRenewalInfo{Signed: last.SignedRenewalInfo, RenewalInfo: info, Err: infoErr},
+			})
+		}
+		decoded.Data = append(decoded.Data, item)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/apitest"
+)
+
+func TestStoreClient_Decoded(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	now := time.Now()
+	s.AddTransaction(api.JWSTransaction{
+		TransactionID:               "2001",
+		ProductID:                   "monthly",
+		SubscriptionGroupIdentifier: "group",
+		Type:                        api.AutoRenewable,
+		ExpiresDate:                 now.Add(time.Hour).UnixMilli(),
+	})
+	s.SetRenewalInfo(api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "2001", AutoRenewStatus: api.AutoRenewStatusOn})
+	s.AddOrder("MTXXXXXXXX", "2001")
+	client := s.Client()
+	ctx := context.Background()
+
+	statuses, err := client.GetALLSubscriptionStatusesDecoded(ctx, "2001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := statuses.Err(); err != nil {
+		t.Fatal(err)
+	}
+	last := statuses.Data[0].LastTransactions[0]
+	if last.Transaction.Transaction.TransactionID != "2001" || last.RenewalInfo.RenewalInfo.AutoRenewStatus != api.AutoRenewStatusOn {
+		t.Errorf("unexpected item %+v", last)
+	}
+
+	order, err := client.LookupOrderIDDecoded(ctx, "MTXXXXXXXX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order.Transactions) != 1 || order.Transactions[0].Transaction.TransactionID != "2001" {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	history, err := client.GetTransactionHistoryDecoded(ctx, "2001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || len(history[0].Transactions) != 1 || history[0].Err() != nil {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
+
+func TestStoreClient_Decoded_ItemErrors(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	valid, err := s.Sign(api.JWSTransaction{TransactionID: "3001", BundleID: "com.example.app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(api.RefundLookupResponse{SignedTransactions: []string{valid, "not.a.jws"}})
+	}))
+	t.Cleanup(srv.Close)
+	config := s.Config()
+	config.HostDebug = srv.URL
+	client := api.NewStoreClientWithHTTPClient(config, srv.Client())
+
+	refunds, err := client.GetRefundHistoryDecoded(context.Background(), "3001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := refunds[0].Transactions
+	if len(txs) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(txs))
+	}
+	if txs[0].Err != nil || txs[0].Transaction.TransactionID != "3001" {
+		t.Errorf("unexpected first transaction %+v", txs[0])
+	}
+	if txs[1].Err == nil || txs[1].Transaction != nil || txs[1].Signed != "not.a.jws" {
+		t.Errorf("unexpected second transaction %+v", txs[1])
+	}
+	if refunds[0].Err() == nil {
+		t.Error("got nil, want the error of the second transaction")
+	}
+}
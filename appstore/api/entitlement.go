@@ -0,0 +1,48 @@
+package api
+
+import (
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/entitlement"
+)
+
+// Entitlement converts the transaction into a normalized entitlement as of now.
+// renewal is optional, when it is given the auto-renew, grace period and billing retry states are taken from it.
+func (J *JWSTransaction) Entitlement(now time.Time, renewal *JWSRenewalInfoDecodedPayload) entitlement.Entitlement {
+	e := entitlement.Entitlement{
+		Store:         entitlement.AppStore,
+		ProductID:     J.ProductID,
+		OriginalID:    J.OriginalTransactionId,
+		TransactionID: J.TransactionID,
+		PeriodStart:   entitlement.FromMillis(J.PurchaseDate),
+		PeriodEnd:     entitlement.FromMillis(J.ExpiresDate),
+		Trial:         J.OfferDiscountType == OfferDiscountTypeFreeTrial,
+		IntroOffer:    J.OfferType == int32(appstore.IntroductoryOffer),
+		// price is in milliunits of the currency
+		PriceMicros: J.Price * 1000,
+		Currency:    J.Currency,
+	}
+	if e.OriginalID == "" {
+		e.OriginalID = J.TransactionID
+	}
+	e.State = entitlement.ExpiredAt(e.PeriodEnd, now)
+
+	if renewal != nil {
+		e.AutoRenew = renewal.AutoRenewStatus == AutoRenewStatusOn
+		if e.State == entitlement.StateExpired {
+			if entitlement.FromMillis(renewal.GracePeriodExpiresDate).After(now) {
+				e.State = entitlement.StateGracePeriod
+			} else if renewal.IsInBillingRetryPeriod != nil && *renewal.IsInBillingRetryPeriod {
+				e.State = entitlement.StateBillingRetry
+			}
+		}
+	}
+
+	if J.RevocationDate != 0 {
+		e.Revoked = true
+		e.RevokedAt = entitlement.FromMillis(J.RevocationDate)
+		e.State = entitlement.StateRevoked
+	}
+	return e
+}
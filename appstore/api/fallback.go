@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+)
+
+// OrderLookupStatus https://developer.apple.com/documentation/appstoreserverapi/orderlookupstatus
+const (
+	OrderLookupValid   = 0
+	OrderLookupInvalid = 1
+)
+
+// environments returns the environment of the config, and the host of the Sandbox when the lookups fall back to it.
+func environments(config *StoreConfig) (Environment, string) {
+	if config.Sandbox {
+		return Sandbox, ""
+	}
+	if !config.SandboxFallback {
+		return Production, ""
+	}
+	return Production, getHost(true, config.HostSandboxDebug)
+}
+
+// sandboxFallback calls the App Store with the host of the client, and again with the Sandbox when notFound
+// and the SandboxFallback of the StoreConfig are true. It returns the environment which answered.
+// TestFlight and App Review transactions are only known by the Sandbox.
+func sandboxFallback[T any](a *StoreClient, notFound func(T, error) bool, call func(host string) (T, error)) (T, Environment, error) {
+	rsp, err := call(a.host)
+	if a.sandboxHost == "" || !notFound(rsp, err) {
+		return rsp, a.environment, err
+	}
+	rsp, err = call(a.sandboxHost)
+	return rsp, Sandbox, err
+}
+
+// hostOf returns the host of env, the Sandbox is only known when the lookups fall back to it.
+func (a *StoreClient) hostOf(env Environment) string {
+	if env == Sandbox && a.sandboxHost != "" {
+		return a.sandboxHost
+	}
+	return a.host
+}
+
+// historyPage fetches a page of a history iterator from the environment of the cursor.
+// The first page resolves the environment with sandboxFallback and records it in the cursor.
+func historyPage[T any](a *StoreClient, cursor *HistoryCursor, fetch func(host string) (T, error)) (T, error) {
+	if cursor.Environment != "" {
+		return fetch(a.hostOf(cursor.Environment))
+	}
+	rsp, env, err := sandboxFallback(a, isNotFound, fetch)
+	if err == nil {
+		cursor.Environment = env
+	}
+	return rsp, err
+}
+
+// isNotFound reports whether the transaction is unknown to the environment.
+func isNotFound[T any](_ T, err error) bool {
+	return errors.Is(err, TransactionIdNotFoundError) || errors.Is(err, OriginalTransactionIdNotFoundError)
+}
+
+// isOrderNotFound reports whether the order is unknown to the environment, which answers with an invalid status.
+func isOrderNotFound(rsp *OrderLookupResponse, err error) bool {
+	return err == nil && rsp != nil && rsp.Status == OrderLookupInvalid
+}
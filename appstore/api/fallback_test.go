@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStoreClient_SandboxFallback(t *testing.T) {
+	t.Parallel()
+	production := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/v1/lookup/"):
+			_, _ = w.Write([]byte(`{"status":1}`))
+		case strings.HasSuffix(r.URL.Path, "/transactions/1001"):
+			_, _ = w.Write([]byte(`{"signedTransactionInfo":"production"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+		}
+	}))
+	t.Cleanup(production.Close)
+	var sandboxCalls atomic.Int32
+	sandbox := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sandboxCalls.Add(1)
+		switch {
+		case strings.Contains(r.URL.Path, "/v1/lookup/"):
+			_, _ = w.Write([]byte(`{"status":0,"signedTransactions":["sandbox"]}`))
+		case strings.Contains(r.URL.Path, "/history/"), strings.Contains(r.URL.Path, "/refund/"):
+			_, _ = w.Write([]byte(`{"hasMore":false,"signedTransactions":["sandbox"]}`))
+		case strings.Contains(r.URL.Path, "/subscriptions/"):
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		default:
+			_, _ = w.Write([]byte(`{"signedTransactionInfo":"sandbox"}`))
+		}
+	}))
+	t.Cleanup(sandbox.Close)
+
+	config := &StoreConfig{
+		KeyContent:       newTestKeyContent(t),
+		KeyID:            "KEYID",
+		BundleID:         "com.example.app",
+		Issuer:           "issuer",
+		SandboxFallback:  true,
+		HostDebug:        production.URL,
+		HostSandboxDebug: sandbox.URL,
+	}
+	client := NewStoreClientWithHTTPClient(config, production.Client())
+	ctx := context.Background()
+
+	info, err := client.GetTransactionInfo(ctx, "1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Environment != Production || info.SignedTransactionInfo != "production" || sandboxCalls.Load() != 0 {
+		t.Errorf("got %+v from %d sandbox calls, want the production answer", info, sandboxCalls.Load())
+	}
+
+	info, err = client.GetTransactionInfo(ctx, "2001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Environment != Sandbox || info.SignedTransactionInfo != "sandbox" {
+		t.Errorf("got %+v, want the sandbox answer", info)
+	}
+
+	order, err := client.LookupOrderID(ctx, "MTXXXXXXXX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Environment != Sandbox || order.Status != OrderLookupValid {
+		t.Errorf("got %+v, want the sandbox answer", order)
+	}
+
+	history, err := client.GetTransactionHistory(ctx, "2001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Environment != Sandbox {
+		t.Errorf("got %+v, want the sandbox history", history)
+	}
+
+	statuses, err := client.GetALLSubscriptionStatuses(ctx, "2001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses.Environment != Sandbox {
+		t.Errorf("got %v, want %v", statuses.Environment, Sandbox)
+	}
+
+	for name, history := range map[string]func(cursor *HistoryCursor) iter.Seq2[*JWSTransaction, error]{
+		"transaction history": func(cursor *HistoryCursor) iter.Seq2[*JWSTransaction, error] {
+			return client.TransactionHistory(ctx, "2001", nil, cursor)
+		},
+		"refund history": func(cursor *HistoryCursor) iter.Seq2[*JWSTransaction, error] {
+			return client.RefundHistory(ctx, "2001", cursor)
+		},
+	} {
+		cursor := &HistoryCursor{}
+		for _, err := range history(cursor) {
+			// the fake transaction is not signed, its page was found in the Sandbox when it fails to decode
+			var itemErr *HistoryItemError
+			if !errors.As(err, &itemErr) {
+				t.Errorf("%s: got %v, want a *HistoryItemError", name, err)
+			}
+		}
+		if cursor.Environment != Sandbox || !cursor.Done {
+			t.Errorf("%s: got %+v, want the sandbox history", name, cursor)
+		}
+	}
+
+	// a cursor of the Sandbox resumes there
+	calls := sandboxCalls.Load()
+	for range client.TransactionHistory(ctx, "2001", nil, &HistoryCursor{Token: "revision", Environment: Sandbox}) {
+	}
+	if got := sandboxCalls.Load() - calls; got != 1 {
+		t.Errorf("got %d sandbox calls, want 1", got)
+	}
+
+	config.SandboxFallback = false
+	client = NewStoreClientWithHTTPClient(config, production.Client())
+	if _, err := client.GetTransactionInfo(ctx, "2001"); !errors.Is(err, TransactionIdNotFoundError) {
+		t.Errorf("got %v, want %v", err, TransactionIdNotFoundError)
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/awa/go-iap/appstore"
+)
+
+// HistoryCursor is the position of a history iterator, which can be saved to resume it later.
+type HistoryCursor struct {
+	// Token is the revision, or the pagination token of the notification history, of the next page.
+	// It is empty for the first page. The iterators advance it once all the items of a page are yielded,
+	// so a stopped iterator resumes at the first page it did not fully yield.
+	Token string
+	// Done is set once the last page is yielded.
+	Done bool
+	// Environment is the environment of the pages, set by the first page of TransactionHistory and RefundHistory,
+	// which fall back to the Sandbox as GetTransactionHistory does.
+	Environment Environment
+}
+
+// HistoryItemError is yielded by the history iterators for an item which fails to decode.
+// The iteration goes on with the next items, and the cursor advances past the page as usual.
+type HistoryItemError struct {
+	// Revision is the token of the page of the item, as HistoryCursor.Token, to fetch the page again.
+	Revision string
+	Err      error
+}
+
+func (e *HistoryItemError) Error() string {
+	return fmt.Sprintf("appstore api: failed to decode an item of page %q: %v", e.Revision, e.Err)
+}
+
+func (e *HistoryItemError) Unwrap() error {
+	return e.Err
+}
+
+// DecodedNotificationHistoryItem is a NotificationHistoryResponseItem with its signed payload verified and decoded.
+type DecodedNotificationHistoryItem struct {
+	Notification *appstore.SubscriptionNotificationV2DecodedPayload
+	NotificationHistoryResponseItem
+}
+
+// TransactionHistory returns an iterator over the decoded transactions of the customer, page by page.
+// The iteration starts at cursor, which is advanced along, or at the first page when cursor is nil.
+// It stops after yielding an error, including the context error when ctx is canceled, except a *HistoryItemError
+// for an item failing verification, which is yielded in its place.
+// Transactions not found in Production are looked up in the Sandbox when the SandboxFallback of the StoreConfig is set.
+// https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
+func (a *StoreClient) TransactionHistory(ctx context.Context, transactionId string, query *url.Values, cursor *HistoryCursor) iter.Seq2[*JWSTransaction, error] {
+	path := strings.Replace(PathTransactionHistory, "{transactionId}", transactionId, -1)
+	if cursor == nil {
+		cursor = &HistoryCursor{}
+	}
+
+	return historyPages(ctx, cursor, func(token string) ([]string, string, bool, error) {
+		q := url.Values{}
+		if query != nil {
+			for k, v := range *query {
+				q[k] = v
+			}
+		}
+		if token != "" {
+			q.Set("revision", token)
+		}
+		rsp, err := historyPage(a, cursor, func(host string) (*HistoryResponse, error) {
+			rsp := &HistoryResponse{}
+			return rsp, a.getJSON(ctx, host+path+"?"+q.Encode(), rsp)
+		})
+		if err != nil {
+			return nil, "", false, err
+		}
+		return rsp.SignedTransactions, rsp.Revision, rsp.HasMore, nil
+	}, func(signed string) (*JWSTransaction, error) {
+		return a.parseSignedTransaction(ctx, signed)
+	})
+}
+
+// RefundHistory returns an iterator over the decoded refunded transactions of the customer, page by page.
+// It resumes, stops and falls back to the Sandbox as TransactionHistory does.
+// https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
+func (a *StoreClient) RefundHistory(ctx context.Context, originalTransactionId string, cursor *HistoryCursor) iter.Seq2[*JWSTransaction, error] {
+	path := strings.Replace(PathRefundHistory, "{originalTransactionId}", originalTransactionId, -1)
+	if cursor == nil {
+		cursor = &HistoryCursor{}
+	}
+
+	return historyPages(ctx, cursor, func(token string) ([]string, string, bool, error) {
+		pagePath := path
+		if token != "" {
+			q := url.Values{}
+			q.Set("revision", token)
+			pagePath += "?" + q.Encode()
+		}
+		rsp, err := historyPage(a, cursor, func(host string) (*RefundLookupResponse, error) {
+			rsp := &RefundLookupResponse{}
+			return rsp, a.getJSON(ctx, host+pagePath, rsp)
+		})
+		if err != nil {
+			return nil, "", false, err
+		}
+		return rsp.SignedTransactions, rsp.Revision, rsp.HasMore, nil
+	}, func(signed string) (*JWSTransaction, error) {
+		return a.parseSignedTransaction(ctx, signed)
+	})
+}
+
+// NotificationHistory returns an iterator over the verified and decoded notifications sent to the app, page by page.
+// It resumes and stops as TransactionHistory does.
+// https://developer.apple.com/documentation/appstoreserverapi/get_notification_history
+func (a *StoreClient) NotificationHistory(ctx context.Context, body NotificationHistoryRequest, cursor *HistoryCursor) iter.Seq2[*DecodedNotificationHistoryItem, error] {
+	return historyPages(ctx, cursor, func(token string) ([]NotificationHistoryResponseItem, string, bool, error) {
+		rsp, err := a.GetNotificationHistory(ctx, body, token)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return rsp.NotificationHistory, rsp.PaginationToken, rsp.HasMore, nil
+	}, func(item NotificationHistoryResponseItem) (*DecodedNotificationHistoryItem, error) {
+		decoded := &DecodedNotificationHistoryItem{
+			Notification:                    &appstore.SubscriptionNotificationV2DecodedPayload{},
+			NotificationHistoryResponseItem: item,
+		}
+		if err := a.parseJWS(ctx, item.SignedPayload, decoded.Notification); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	})
+}
+
+// getJSON gets URL and decodes the response into v.
+func (a *StoreClient) getJSON(ctx context.Context, URL string, v interface{}) error {
+	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// historyPages iterates over the pages fetched by page, yielding their items decoded by decode.
+// An item failing to decode is yielded as a *HistoryItemError and the iteration goes on.
+func historyPages[R, T any](ctx context.Context, cursor *HistoryCursor,
+	page func(token string) (items []R, next string, hasMore bool, err error),
+	decode func(R) (T, error)) iter.Seq2[T, error] {
+	if cursor == nil {
+		cursor = &HistoryCursor{}
+	}
+	return func(yield func(T, error) bool) {
+		var zero T
+		for !cursor.Done {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			items, next, hasMore, err := page(cursor.Token)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				v, err := decode(item)
+				if err != nil {
+					if !yield(zero, &HistoryItemError{Revision: cursor.Token, Err: err}) {
+						return
+					}
+					continue
+				}
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if !hasMore {
+				cursor.Done = true
+				return
+			}
+			if next == "" {
+				yield(zero, errors.New("appstore api: page has more items but no token to fetch them"))
+				return
+			}
+			cursor.Token = next
+		}
+	}
+}
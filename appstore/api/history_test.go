@@ -0,0 +1,218 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/apitest"
+)
+
+func newHistoryTestServer(t *testing.T) *apitest.Server {
+	t.Helper()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	s.PageSize = 2
+	now := time.Now()
+	for i, id := range []string{"1001", "1002", "1003", "1004", "1005"} {
+		tx := api.JWSTransaction{
+			TransactionID:         id,
+			OriginalTransactionId: "1001",
+			ProductID:             "monthly",
+			Type:                  api.AutoRenewable,
+			PurchaseDate:          now.AddDate(0, i-5, 0).UnixMilli(),
+			ExpiresDate:           now.AddDate(0, i-4, 0).UnixMilli(),
+		}
+		if i%2 == 0 {
+			tx.RevocationDate = tx.PurchaseDate
+		}
+		s.AddTransaction(tx)
+	}
+	return s
+}
+
+func TestStoreClient_TransactionHistory(t *testing.T) {
+	t.Parallel()
+	s := newHistoryTestServer(t)
+	client := s.Client()
+	ctx := context.Background()
+
+	// stop in the middle of the second page, then resume from the saved cursor
+	cursor := &api.HistoryCursor{}
+	var ids []string
+	for tx, err := range client.TransactionHistory(ctx, "1001", nil, cursor) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.TransactionID)
+		if len(ids) == 3 {
+			break
+		}
+	}
+	if cursor.Token == "" || cursor.Done {
+		t.Fatalf("unexpected cursor %+v", cursor)
+	}
+	saved := *cursor
+	for tx, err := range client.TransactionHistory(ctx, "1001", nil, &saved) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.TransactionID)
+	}
+	want := []string{"1001", "1002", "1003", "1003", "1004", "1005"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if !saved.Done {
+		t.Error("cursor is not done")
+	}
+
+	query := &url.Values{}
+	query.Set("sort", "DESCENDING")
+	ids = nil
+	for tx, err := range client.TransactionHistory(ctx, "1001", query, nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.TransactionID)
+	}
+	if want := []string{"1005", "1004", "1003", "1002", "1001"}; !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
+
+func TestStoreClient_TransactionHistory_Errors(t *testing.T) {
+	t.Parallel()
+	s := newHistoryTestServer(t)
+	client := s.Client()
+
+	var got error
+	for _, err := range client.TransactionHistory(context.Background(), "unknown", nil, nil) {
+		got = err
+	}
+	if !errors.Is(got, api.TransactionIdNotFoundError) {
+		t.Errorf("got %v, want %v", got, api.TransactionIdNotFoundError)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got = nil
+	for _, err := range client.TransactionHistory(ctx, "1001", nil, nil) {
+		got = err
+	}
+	if !errors.Is(got, context.Canceled) {
+		t.Errorf("got %v, want %v", got, context.Canceled)
+	}
+}
+
+// corruptingTransport replaces the first signed transaction of the second page with an invalid one.
+type corruptingTransport struct {
+	once sync.Once
+}
+
+func (c *corruptingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rsp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || req.URL.Query().Get("revision") == "" {
+		return rsp, err
+	}
+	corrupt := false
+	c.once.Do(func() { corrupt = true })
+	if !corrupt {
+		return rsp, nil
+	}
+	defer rsp.Body.Close()
+	var page map[string]any
+	if err := json.NewDecoder(rsp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	page["signedTransactions"].([]any)[0] = "invalid"
+	body, _ := json.Marshal(page)
+	rsp.Body = io.NopCloser(bytes.NewReader(body))
+	rsp.ContentLength = int64(len(body))
+	return rsp, nil
+}
+
+func TestStoreClient_TransactionHistory_InvalidItem(t *testing.T) {
+	t.Parallel()
+	s := newHistoryTestServer(t)
+	client := api.NewStoreClientWithHTTPClient(s.Config(), &http.Client{Transport: &corruptingTransport{}})
+
+	cursor := &api.HistoryCursor{}
+	var ids []string
+	var itemErrs []*api.HistoryItemError
+	for tx, err := range client.TransactionHistory(context.Background(), "1001", nil, cursor) {
+		var itemErr *api.HistoryItemError
+		switch {
+		case errors.As(err, &itemErr):
+			itemErrs = append(itemErrs, itemErr)
+		case err != nil:
+			t.Fatal(err)
+		default:
+			ids = append(ids, tx.TransactionID)
+		}
+	}
+	if want := []string{"1001", "1002", "1004", "1005"}; !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if len(itemErrs) != 1 || itemErrs[0].Revision == "" {
+		t.Errorf("got %v, want one error with the revision of the second page", itemErrs)
+	}
+	if !cursor.Done {
+		t.Errorf("got cursor %+v, want done", cursor)
+	}
+}
+
+func TestStoreClient_RefundHistory(t *testing.T) {
+	t.Parallel()
+	s := newHistoryTestServer(t)
+	client := s.Client()
+
+	var ids []string
+	for tx, err := range client.RefundHistory(context.Background(), "1001", nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.TransactionID)
+	}
+	if want := []string{"1001", "1003", "1005"}; !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
+
+func TestStoreClient_NotificationHistory(t *testing.T) {
+	t.Parallel()
+	s := newHistoryTestServer(t)
+	for i := 0; i < 3; i++ {
+		s.AddNotification(appstore.SubscriptionNotificationV2DecodedPayload{NotificationType: appstore.NotificationTypeV2DidRenew})
+	}
+	client := s.Client()
+	request := api.NotificationHistoryRequest{StartDate: time.Now().Add(-time.Hour).UnixMilli(), EndDate: time.Now().Add(time.Hour).UnixMilli()}
+
+	cursor := &api.HistoryCursor{}
+	var n int
+	for item, err := range client.NotificationHistory(context.Background(), request, cursor) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.SignedPayload == "" || item.Notification.NotificationType != appstore.NotificationTypeV2DidRenew {
+			t.Errorf("unexpected notification %+v", item)
+		}
+		n++
+	}
+	if n != 3 || !cursor.Done {
+		t.Errorf("got %d notifications and cursor %+v, want 3 and done", n, cursor)
+	}
+}
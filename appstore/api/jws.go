@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/awa/go-iap/appstore"
+)
+
+// list of errors returned while decoding a JWS
+var (
+	ErrMalformedJWS      = errors.New("appstore api: malformed JWS")
+	ErrUnknownJWSPayload = errors.New("appstore api: unknown JWS payload")
+)
+
+// JWSPayloadType is the type of a signed payload of the App Store.
+type JWSPayloadType string
+
+const (
+	JWSPayloadTransaction    JWSPayloadType = "transaction"
+	JWSPayloadRenewalInfo    JWSPayloadType = "renewalInfo"
+	JWSPayloadAppTransaction JWSPayloadType = "appTransaction"
+	JWSPayloadNotification   JWSPayloadType = "notification"
+	// JWSPayloadSummary is a notification carrying a summary instead of data.
+	JWSPayloadSummary JWSPayloadType = "summary"
+)
+
+// DecodedJWS is a signed payload verified and decoded by DecodeJWS. The field of its Type is set.
+// The signed transaction and renewal info of a notification stay signed.
+type DecodedJWS struct {
+	Type           JWSPayloadType
+	Transaction    *JWSTransaction
+	RenewalInfo    *JWSRenewalInfoDecodedPayload
+	AppTransaction *AppTransaction
+	// Notification is set for both JWSPayloadNotification and JWSPayloadSummary.
+	// It is the type of the appstore package, which decodes notifications without importing this package.
+	Notification *appstore.SubscriptionNotificationV2DecodedPayload
+}
+
+// Value returns the decoded payload of the type, e.g. a *JWSTransaction.
+func (d *DecodedJWS) Value() interface{} {
+	switch d.Type {
+	case JWSPayloadTransaction:
+		return d.Transaction
+	case JWSPayloadRenewalInfo:
+		return d.RenewalInfo
+	case JWSPayloadAppTransaction:
+		return d.AppTransaction
+	default:
+		return d.Notification
+	}
+}
+
+// DetectJWSPayloadType returns the type of a signed payload from the fields of its payload, without verifying it.
+func DetectJWSPayloadType(jwsEncode string) (JWSPayloadType, error) {
+	parts := strings.Split(jwsEncode, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: got %d parts, want 3", ErrMalformedJWS, len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedJWS, err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedJWS, err)
+	}
+
+	has := func(name string) bool {
+		_, ok := fields[name]
+		return ok
+	}
+	switch {
+	case has("notificationType"):
+		// the summary is only sent with the SUMMARY subtype of RENEWAL_EXTENSION
+		// https://developer.apple.com/documentation/appstoreservernotifications/summary
+		var subtype appstore.SubtypeV2
+		if raw, ok := fields["subtype"]; ok {
+			_ = json.Unmarshal(raw, &subtype)
+		}
+		if subtype == appstore.SubTypeV2Summary {
+			return JWSPayloadSummary, nil
+		}
+		return JWSPayloadNotification, nil
+	case has("transactionId"):
+		return JWSPayloadTransaction, nil
+	case has("originalApplicationVersion") || has("receiptType"):
+		return JWSPayloadAppTransaction, nil
+	case has("autoRenewProductId") || has("autoRenewStatus"):
+		return JWSPayloadRenewalInfo, nil
+	}
+	return "", ErrUnknownJWSPayload
+}
+
+// DecodeJWS detects the type of a signed payload, then verifies and decodes it.
+func (a *StoreClient) DecodeJWS(jwsEncode string) (*DecodedJWS, error) {
+	payloadType, err := DetectJWSPayloadType(jwsEncode)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &DecodedJWS{Type: payloadType}
+	var claims jwt.Claims
+	switch payloadType {
+	case JWSPayloadTransaction:
+		decoded.Transaction = &JWSTransaction{}
+		claims = decoded.Transaction
+	case JWSPayloadRenewalInfo:
+		decoded.RenewalInfo = &JWSRenewalInfoDecodedPayload{}
+		claims = decoded.RenewalInfo
+	case JWSPayloadAppTransaction:
+		decoded.AppTransaction = &AppTransaction{}
+		claims = decoded.AppTransaction
+	case JWSPayloadNotification, JWSPayloadSummary:
+		decoded.Notification = &appstore.SubscriptionNotificationV2DecodedPayload{}
+		claims = decoded.Notification
+	}
+	if err := a.parseJWS(context.Background(), jwsEncode, claims); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
@@ -0,0 +1,137 @@
+package api_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/apitest"
+)
+
+func TestStoreClient_DecodeJWS(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	client := s.Client()
+
+	tests := []struct {
+		name   string
+		claims jwt.Claims
+		want   api.JWSPayloadType
+	}{
+		{name: "transaction", claims: api.JWSTransaction{TransactionID: "1001", BundleID: "com.example.app"}, want: api.JWSPayloadTransaction},
+		{name: "renewal info", claims: api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "1001", AutoRenewProductId: "monthly"}, want: api.JWSPayloadRenewalInfo},
+		{name: "app transaction", claims: api.AppTransaction{BundleId: "com.example.app", OriginalApplicationVersion: "1.0", ReceiptType: api.Sandbox}, want: api.JWSPayloadAppTransaction},
+		{name: "notification", claims: appstore.SubscriptionNotificationV2DecodedPayload{NotificationType: appstore.NotificationTypeV2DidRenew}, want: api.JWSPayloadNotification},
+		{name: "summary", claims: appstore.SubscriptionNotificationV2DecodedPayload{
+			NotificationType: appstore.NotificationTypeV2RenewalExtension,
+			Subtype:          appstore.SubTypeV2Summary,
+			Summary:          appstore.SubscriptionNotificationV2Summary{RequestIdentifier: "req", SucceededCount: 3},
+		}, want: api.JWSPayloadSummary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := s.Sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := client.DecodeJWS(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Type != tt.want {
+				t.Errorf("got %v, want %v", decoded.Type, tt.want)
+			}
+			if decoded.Value() == nil {
+				t.Errorf("got nil value for %v", decoded.Type)
+			}
+			parsed, err := client.ParseJWSEncodeString(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed == nil {
+				t.Errorf("got nil, want the %v", tt.want)
+			}
+		})
+	}
+
+	summary, err := s.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2RenewalExtension,
+		Subtype:          appstore.SubTypeV2Summary,
+		Summary:          appstore.SubscriptionNotificationV2Summary{RequestIdentifier: "req", SucceededCount: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := client.DecodeJWS(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Notification.Summary.SucceededCount != 3 {
+		t.Errorf("got %v, want 3", decoded.Notification.Summary.SucceededCount)
+	}
+}
+
+func TestStoreClient_DecodeJWS_MalformedX5c(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	client := s.Client()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"transactionId":"1001","bundleId":"com.example.app"}`))
+
+	tests := map[string]string{
+		"no x5c":    `{"alg":"ES256"}`,
+		"empty x5c": `{"alg":"ES256","x5c":[]}`,
+		"short x5c": `{"alg":"ES256","x5c":["AAAA","AAAA"]}`,
+		"long x5c":  `{"alg":"ES256","x5c":["AAAA","AAAA","AAAA","AAAA"]}`,
+		"header":    `not json`,
+	}
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			jws := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + payload + ".sig"
+			if _, err := client.DecodeJWS(jws); !errors.Is(err, api.ErrMalformedJWS) {
+				t.Errorf("DecodeJWS: got %v, want %v", err, api.ErrMalformedJWS)
+			}
+			if _, err := client.ParseJWSEncodeString(jws); !errors.Is(err, api.ErrMalformedJWS) {
+				t.Errorf("ParseJWSEncodeString: got %v, want %v", err, api.ErrMalformedJWS)
+			}
+		})
+	}
+}
+
+func TestDetectJWSPayloadType_Errors(t *testing.T) {
+	t.Parallel()
+	encode := func(payload string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+	}
+
+	tests := []struct {
+		name    string
+		jws     string
+		wantErr error
+	}{
+		{name: "two parts", jws: "e30.e30", wantErr: api.ErrMalformedJWS},
+		{name: "payload not base64", jws: "e30.!!!.sig", wantErr: api.ErrMalformedJWS},
+		{name: "payload not json", jws: encode("[1,2]"), wantErr: api.ErrMalformedJWS},
+		{name: "unknown payload", jws: encode(`{"renewalDateLike":1,"note":"transactionId"}`), wantErr: api.ErrUnknownJWSPayload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := api.DetectJWSPayloadType(tt.jws); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultKeyCacheDuration is how long the key of a KeyProvider is used before asking it again.
+const DefaultKeyCacheDuration = 5 * time.Minute
+
+// DefaultKeyDrainTimeout is how long a new key waits for the requests of the previous key, the timeout of the default http.Client.
+const DefaultKeyDrainTimeout = 30 * time.Second
+
+// ErrKeyNotFound is returned by the providers when there is no key.
+var ErrKeyNotFound = errors.New("appstore api: key not found")
+
+// SigningKey is a private key from App Store Connect.
+type SigningKey struct {
+	KeyID   string // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
+	Content []byte // The .p8 certificate
+}
+
+// KeyProvider supplies the key signing the bearer tokens, e.g. from a secrets manager.
+// Returning a different key rotates it, see Token.KeyProvider.
+type KeyProvider interface {
+	SigningKey(ctx context.Context) (*SigningKey, error)
+}
+
+// KeyProviderFunc is a function implementing KeyProvider.
+type KeyProviderFunc func(ctx context.Context) (*SigningKey, error)
+
+// SigningKey implements KeyProvider.
+func (f KeyProviderFunc) SigningKey(ctx context.Context) (*SigningKey, error) {
+	return f(ctx)
+}
+
+// FileKeyProvider reads the key from a .p8 file, so replacing the file rotates the key.
+type FileKeyProvider struct {
+	Path string
+	// KeyID is parsed from a file name like AuthKey_2X9R4HXF34.p8 when empty.
+	KeyID string
+}
+
+// SigningKey implements KeyProvider.
+func (p *FileKeyProvider) SigningKey(ctx context.Context) (*SigningKey, error) {
+	content, err := os.ReadFile(p.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, err)
+	} else if err != nil {
+		return nil, err
+	}
+	keyID := p.KeyID
+	if keyID == "" {
+		keyID = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p.Path), "AuthKey_"), ".p8")
+	}
+	return &SigningKey{KeyID: keyID, Content: content}, nil
+}
+
+// EnvKeyProvider reads the key from environment variables.
+// The key content is either the .p8 certificate or its base64 encoding.
+type EnvKeyProvider struct {
+	KeyIDVar   string
+	ContentVar string
+}
+
+// SigningKey implements KeyProvider.
+func (p *EnvKeyProvider) SigningKey(ctx context.Context) (*SigningKey, error) {
+	keyID := os.Getenv(p.KeyIDVar)
+	value := strings.TrimSpace(os.Getenv(p.ContentVar))
+	if keyID == "" || value == "" {
+		return nil, fmt.Errorf("%w: %s and %s must be set", ErrKeyNotFound, p.KeyIDVar, p.ContentVar)
+	}
+	content := []byte(value)
+	if !strings.HasPrefix(value, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, ErrAuthKeyInvalidPem
+		}
+		content = decoded
+	}
+	return &SigningKey{KeyID: keyID, Content: content}, nil
+}
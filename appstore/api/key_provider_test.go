@@ -0,0 +1,304 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKeyContent(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func bearerKeyID(t *testing.T, bearer string) string {
+	t.Helper()
+	token, _, err := jwt.NewParser().ParseUnverified(bearer, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestToken_FileKeyProvider(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "AuthKey_KEYA.p8")
+	if err := os.WriteFile(path, newTestKeyContent(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider := &FileKeyProvider{Path: path}
+	token := &Token{KeyProvider: provider, KeyCacheDuration: time.Nanosecond}
+
+	bearer, err := token.GenerateIfExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bearerKeyID(t, bearer); got != "KEYA" {
+		t.Errorf("got %v, want KEYA", got)
+	}
+
+	provider.KeyID = "KEYB"
+	if err := os.WriteFile(path, newTestKeyContent(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bearer, err = token.GenerateIfExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bearerKeyID(t, bearer); got != "KEYB" {
+		t.Errorf("got %v, want KEYB", got)
+	}
+
+	// the cached key is kept when the provider fails
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := token.GenerateIfExpired(); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	if _, err := (&Token{KeyProvider: provider}).GenerateIfExpired(); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("got %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestToken_RotationWaitsForInflight(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	key := &SigningKey{KeyID: "KEYA", Content: newTestKeyContent(t)}
+	token := &Token{
+		KeyProvider: KeyProviderFunc(func(ctx context.Context) (*SigningKey, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return key, nil
+		}),
+		KeyCacheDuration: time.Nanosecond,
+	}
+
+	bearer, release, err := token.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bearerKeyID(t, bearer); got != "KEYA" {
+		t.Errorf("got %v, want KEYA", got)
+	}
+
+	mu.Lock()
+	key = &SigningKey{KeyID: "KEYB", Content: newTestKeyContent(t)}
+	mu.Unlock()
+	acquired := make(chan string)
+	go func() {
+		bearer, release, err := token.Acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+		} else {
+			release()
+		}
+		acquired <- bearer
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the new key is used before the in-flight request is done")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	if got := bearerKeyID(t, <-acquired); got != "KEYB" {
+		t.Errorf("got %v, want KEYB", got)
+	}
+}
+
+func TestToken_RotationWait(t *testing.T) {
+	t.Parallel()
+	newToken := func(drainTimeout time.Duration) (*Token, func()) {
+		var mu sync.Mutex
+		key := &SigningKey{KeyID: "KEYA", Content: newTestKeyContent(t)}
+		token := &Token{
+			KeyProvider: KeyProviderFunc(func(ctx context.Context) (*SigningKey, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				return key, nil
+			}),
+			KeyCacheDuration: time.Nanosecond,
+			KeyDrainTimeout:  drainTimeout,
+		}
+		return token, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			key = &SigningKey{KeyID: "KEYB", Content: newTestKeyContent(t)}
+		}
+	}
+
+	t.Run("context canceled", func(t *testing.T) {
+		t.Parallel()
+		token, rotate := newToken(0)
+		_, release, err := token.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+		rotate()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, _, err := token.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("acquired twice", func(t *testing.T) {
+		t.Parallel()
+		token, rotate := newToken(20 * time.Millisecond)
+		_, release, err := token.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotate()
+
+		// the bearer is not released, the new key is used after KeyDrainTimeout
+		bearer, releaseSecond, err := token.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bearerKeyID(t, bearer); got != "KEYB" {
+			t.Errorf("got %v, want KEYB", got)
+		}
+		release()
+		release()
+		releaseSecond()
+		if token.inflight != 0 {
+			t.Errorf("got %d in-flight bearers, want 0", token.inflight)
+		}
+	})
+}
+
+func TestToken_KeyProviderOutsideLock(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	unblock := make(chan struct{})
+	content := newTestKeyContent(t)
+	token := &Token{
+		KeyProvider: KeyProviderFunc(func(ctx context.Context) (*SigningKey, error) {
+			if calls.Add(1) > 1 {
+				<-unblock
+			}
+			return &SigningKey{KeyID: "KEYA", Content: content}, nil
+		}),
+		KeyCacheDuration: time.Nanosecond,
+	}
+	if _, err := token.GenerateIfExpired(); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := make(chan error)
+	go func() {
+		_, err := token.GenerateIfExpired()
+		fetched <- err
+	}()
+	for calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the provider is blocked, the other requests keep signing with the current key
+	done := make(chan string)
+	go func() {
+		bearer, err := token.GenerateIfExpired()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- bearer
+	}()
+	select {
+	case bearer := <-done:
+		if got := bearerKeyID(t, bearer); got != "KEYA" {
+			t.Errorf("got %v, want KEYA", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the request waits for the KeyProvider")
+	}
+	close(unblock)
+	if err := <-fetched; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToken_InvalidRotatedKey(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	key := &SigningKey{KeyID: "KEYA", Content: newTestKeyContent(t)}
+	var logged []error
+	token := &Token{
+		KeyProvider: KeyProviderFunc(func(ctx context.Context) (*SigningKey, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return key, nil
+		}),
+		KeyCacheDuration: time.Nanosecond,
+		KeyErrorLog:      func(err error) { logged = append(logged, err) },
+	}
+	if _, err := token.GenerateIfExpired(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	key = &SigningKey{KeyID: "KEYB", Content: []byte("not a key")}
+	mu.Unlock()
+	bearer, err := token.GenerateIfExpired()
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if got := bearerKeyID(t, bearer); got != "KEYA" {
+		t.Errorf("got %v, want KEYA", got)
+	}
+	if len(logged) != 1 || !errors.Is(logged[0], ErrAuthKeyInvalidPem) {
+		t.Errorf("got %v, want %v", logged, ErrAuthKeyInvalidPem)
+	}
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	content := newTestKeyContent(t)
+	t.Setenv("TEST_APPSTORE_KEY_ID", "KEYA")
+	t.Setenv("TEST_APPSTORE_KEY", base64.StdEncoding.EncodeToString(content))
+	provider := &EnvKeyProvider{KeyIDVar: "TEST_APPSTORE_KEY_ID", ContentVar: "TEST_APPSTORE_KEY"}
+
+	key, err := provider.SigningKey(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.KeyID != "KEYA" || string(key.Content) != string(content) {
+		t.Errorf("unexpected key %+v", key)
+	}
+
+	t.Setenv("TEST_APPSTORE_KEY", string(content))
+	if key, err = provider.SigningKey(context.Background()); err != nil || string(key.Content) != strings.TrimSpace(string(content)) {
+		t.Errorf("got %v, want the PEM content", err)
+	}
+
+	t.Setenv("TEST_APPSTORE_KEY", "")
+	if _, err := provider.SigningKey(context.Background()); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("got %v, want %v", err, ErrKeyNotFound)
+	}
+}
@@ -9,6 +9,8 @@ import (
 type OrderLookupResponse struct {
 	Status             int      `json:"status"`
 	SignedTransactions []string `json:"signedTransactions"`
+	// Environment is the environment which answered, set by the client.
+	Environment Environment `json:"-"`
 }
 
 type Environment string
@@ -32,6 +34,8 @@ type HistoryResponse struct {
 // TransactionInfoResponse https://developer.apple.com/documentation/appstoreserverapi/transactioninforesponse
 type TransactionInfoResponse struct {
 	SignedTransactionInfo string `json:"signedTransactionInfo"`
+	// Environment is the environment which answered, set by the client.
+	Environment Environment `json:"-"`
 }
 
 // RefundLookupResponse same as the RefundHistoryResponse https://developer.apple.com/documentation/appstoreserverapi/refundhistoryresponse
@@ -338,6 +342,10 @@ const (
 
 	AutoRenewStatusOff AutoRenewStatus = 0
 	AutoRenewStatusOn  AutoRenewStatus = 1
+
+	// PriceIncreaseStatus of JWSRenewalInfoDecodedPayload https://developer.apple.com/documentation/appstoreserverapi/priceincreasestatus
+	PriceIncreaseStatusNotConsented int32 = 0
+	PriceIncreaseStatusConsented    int32 = 1
 )
 
 type UpdateAppAccountTokenRequest struct {
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidOffer is returned when a field required to sign an offer is missing.
+var ErrInvalidOffer = errors.New("appstore api: invalid offer")
+
+// audiences of the JWS signed offers
+const (
+	audiencePromotionalOffer             = "promotional-offer"
+	audienceIntroductoryOfferEligibility = "introductory-offer-eligibility"
+)
+
+// PromotionalOfferSignature is what StoreKit needs to redeem a promotional offer signed with the legacy format.
+// https://developer.apple.com/documentation/storekit/in-app_purchase/original_api_for_in-app_purchase/subscriptions_and_offers/generating_a_signature_for_promotional_offers
+type PromotionalOfferSignature struct {
+	KeyID     string
+	Nonce     uuid.UUID
+	Timestamp int64 // milliseconds
+	Signature string
+}
+
+// OfferSigner signs the promotional and introductory offers with the key of a StoreConfig.
+// The key must be a subscription key from App Store Connect.
+// Win-back offers are redeemed with the promotional offer format, sign them with SignPromotionalOfferJWS.
+type OfferSigner struct {
+	KeyID    string
+	BundleID string
+	Issuer   string
+	Now      func() time.Time // The time of the JWS signed offers. Default is time.Now.
+
+	key *ecdsa.PrivateKey
+}
+
+// NewOfferSigner loads the key of the config, from its KeyProvider when set.
+// The key is loaded once, create a new OfferSigner to use a rotated key.
+func NewOfferSigner(ctx context.Context, config *StoreConfig) (*OfferSigner, error) {
+	keyID, content := config.KeyID, config.KeyContent
+	if config.KeyProvider != nil {
+		signingKey, err := config.KeyProvider.SigningKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keyID, content = signingKey.KeyID, signingKey.Content
+	}
+	key, err := (&Token{}).passKeyFromByte(content)
+	if err != nil {
+		return nil, err
+	}
+	return &OfferSigner{
+		KeyID:    keyID,
+		BundleID: config.BundleID,
+		Issuer:   config.Issuer,
+		key:      key,
+	}, nil
+}
+
+// SignPromotionalOffer signs a promotional offer with the legacy nonce based format.
+// appAccountToken is the applicationUsername of the payment, it can be empty.
+// https://developer.apple.com/documentation/storekit/in-app_purchase/original_api_for_in-app_purchase/subscriptions_and_offers/generating_a_signature_for_promotional_offers
+func (s *OfferSigner) SignPromotionalOffer(productId, offerId, appAccountToken string, nonce uuid.UUID, timestamp time.Time) (*PromotionalOfferSignature, error) {
+	if productId == "" || offerId == "" {
+		return nil, fmt.Errorf("%w: productId and offerId are required", ErrInvalidOffer)
+	}
+	ts := timestamp.UnixMilli()
+	// the fields are joined with the invisible separator U+2063
+	payload := strings.Join([]string{
+		s.BundleID,
+		s.KeyID,
+		productId,
+		offerId,
+		strings.ToLower(appAccountToken),
+		strings.ToLower(nonce.String()),
+		strconv.FormatInt(ts, 10),
+	}, "\u2063")
+
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return &PromotionalOfferSignature{
+		KeyID:     s.KeyID,
+		Nonce:     nonce,
+		Timestamp: ts,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}, nil
+}
+
+// SignPromotionalOfferJWS signs a promotional or win-back offer with the JWS format of StoreKit.
+// transactionId is the ID of any transaction of the customer, it is omitted when empty.
+// https://developer.apple.com/documentation/storekit/generating-jws-to-sign-app-store-requests
+func (s *OfferSigner) SignPromotionalOfferJWS(productId, offerId, transactionId string) (string, error) {
+	if productId == "" || offerId == "" {
+		return "", fmt.Errorf("%w: productId and offerId are required", ErrInvalidOffer)
+	}
+	claims := jwt.MapClaims{
+		"productId":       productId,
+		"offerIdentifier": offerId,
+	}
+	if transactionId != "" {
+		claims["transactionId"] = transactionId
+	}
+	return s.signJWS(audiencePromotionalOffer, claims)
+}
+
+// SignIntroductoryOfferEligibility signs whether the customer of transactionId can redeem the introductory offer of the product.
+// https://developer.apple.com/documentation/storekit/generating-jws-to-sign-app-store-requests
+func (s *OfferSigner) SignIntroductoryOfferEligibility(productId string, allowIntroductoryOffer bool, transactionId string) (string, error) {
+	if productId == "" || transactionId == "" {
+		return "", fmt.Errorf("%w: productId and transactionId are required", ErrInvalidOffer)
+	}
+	return s.signJWS(audienceIntroductoryOfferEligibility, jwt.MapClaims{
+		"productId":              productId,
+		"allowIntroductoryOffer": allowIntroductoryOffer,
+		"transactionId":          transactionId,
+	})
+}
+
+func (s *OfferSigner) signJWS(audience string, claims jwt.MapClaims) (string, error) {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	claims["iss"] = s.Issuer
+	claims["iat"] = now.Unix()
+	claims["aud"] = audience
+	claims["bid"] = s.BundleID
+	claims["nonce"] = uuid.New()
+
+	token := &jwt.Token{
+		Header: map[string]interface{}{
+			"alg": "ES256",
+			"kid": s.KeyID,
+			"typ": "JWT",
+		},
+		Claims: claims,
+		Method: jwt.SigningMethodES256,
+	}
+	return token.SignedString(s.key)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newTestOfferSigner(t *testing.T) (*OfferSigner, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewOfferSigner(context.Background(), &StoreConfig{
+		KeyContent: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		KeyID:      "KEYID",
+		BundleID:   "com.example.app",
+		Issuer:     "issuer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, key
+}
+
+func TestNewOfferSigner_KeyProvider(t *testing.T) {
+	t.Parallel()
+	content := newTestKeyContent(t)
+	signer, err := NewOfferSigner(context.Background(), &StoreConfig{
+		KeyProvider: KeyProviderFunc(func(ctx context.Context) (*SigningKey, error) {
+			return &SigningKey{KeyID: "KEYB", Content: content}, nil
+		}),
+		BundleID: "com.example.app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.KeyID != "KEYB" {
+		t.Errorf("got %v, want KEYB", signer.KeyID)
+	}
+
+	_, err = NewOfferSigner(context.Background(), &StoreConfig{
+		KeyProvider: KeyProviderFunc(func(ctx context.Context) (*SigningKey, error) {
+			return nil, ErrKeyNotFound
+		}),
+	})
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("got %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestOfferSigner_SignPromotionalOffer(t *testing.T) {
+	t.Parallel()
+	signer, key := newTestOfferSigner(t)
+	nonce := uuid.MustParse("A8D5C3B2-2B1F-4E8C-9C1A-1D2E3F4A5B6C")
+	timestamp := time.UnixMilli(1700000000123)
+
+	got, err := signer.SignPromotionalOffer("monthly", "WINBACK50", "", nonce, timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.KeyID != "KEYID" || got.Nonce != nonce || got.Timestamp != 1700000000123 {
+		t.Errorf("unexpected signature %+v", got)
+	}
+	sig, err := base64.StdEncoding.DecodeString(got.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := "com.example.app\u2063KEYID\u2063monthly\u2063WINBACK50\u2063\u2063a8d5c3b2-2b1f-4e8c-9c1a-1d2e3f4a5b6c\u20631700000000123"
+	digest := sha256.Sum256([]byte(payload))
+	if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig) {
+		t.Error("signature does not verify")
+	}
+
+	if _, err := signer.SignPromotionalOffer("monthly", "", "", nonce, timestamp); !errors.Is(err, ErrInvalidOffer) {
+		t.Errorf("got %v, want %v", err, ErrInvalidOffer)
+	}
+}
+
+func TestOfferSigner_SignJWS(t *testing.T) {
+	t.Parallel()
+	signer, key := newTestOfferSigner(t)
+	now := time.Unix(1700000000, 0)
+	signer.Now = func() time.Time { return now }
+
+	promotional, err := signer.SignPromotionalOfferJWS("monthly", "PROMO", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	winBack, err := signer.SignPromotionalOfferJWS("monthly", "WINBACK", "2001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	introductory, err := signer.SignIntroductoryOfferEligibility("monthly", false, "2001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		jws  string
+		want jwt.MapClaims
+	}{
+		{name: "promotional", jws: promotional, want: jwt.MapClaims{"aud": "promotional-offer", "offerIdentifier": "PROMO", "transactionId": nil}},
+		{name: "win-back", jws: winBack, want: jwt.MapClaims{"aud": "promotional-offer", "offerIdentifier": "WINBACK", "transactionId": "2001"}},
+		{name: "introductory", jws: introductory, want: jwt.MapClaims{"aud": "introductory-offer-eligibility", "allowIntroductoryOffer": false, "transactionId": "2001"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{}
+			token, err := jwt.ParseWithClaims(tt.jws, claims, func(*jwt.Token) (interface{}, error) {
+				return &key.PublicKey, nil
+			}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithoutClaimsValidation())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Header["kid"] != "KEYID" {
+				t.Errorf("got kid %v, want KEYID", token.Header["kid"])
+			}
+			if claims["iss"] != "issuer" || claims["bid"] != "com.example.app" || claims["iat"] != float64(now.Unix()) || claims["productId"] != "monthly" || claims["nonce"] == "" {
+				t.Errorf("unexpected common claims %v", claims)
+			}
+			for k, v := range tt.want {
+				if claims[k] != v {
+					t.Errorf("got %v %v, want %v", k, claims[k], v)
+				}
+			}
+		})
+	}
+
+	if _, err := signer.SignIntroductoryOfferEligibility("monthly", true, ""); !errors.Is(err, ErrInvalidOffer) {
+		t.Errorf("got %v, want %v", err, ErrInvalidOffer)
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+)
+
+// ErrUnknownBundleID is returned when a StorePool has no config for a bundle ID.
+var ErrUnknownBundleID = errors.New("appstore api: unknown bundle id")
+
+// StorePool holds the configs of many apps and routes the calls to their StoreClient by bundle ID.
+// The clients share one http.Client and are created on first use. It is safe for concurrent use.
+type StorePool struct {
+	httpCli *http.Client
+
+	mu      sync.RWMutex
+	configs map[string]*StoreConfig
+	clients map[string]*StoreClient
+}
+
+// NewStorePool creates a pool of the configs, keyed by their BundleID.
+// httpClient is shared by every client, a client with a 30 seconds timeout is used when nil.
+func NewStorePool(httpClient *http.Client, configs ...*StoreConfig) *StorePool {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	p := &StorePool{
+		httpCli: httpClient,
+		configs: make(map[string]*StoreConfig, len(configs)),
+		clients: make(map[string]*StoreClient, len(configs)),
+	}
+	for _, config := range configs {
+		p.configs[config.BundleID] = config
+	}
+	return p
+}
+
+// Add adds the config of an app, replacing the client of its bundle ID if any.
+func (p *StorePool) Add(config *StoreConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configs[config.BundleID] = config
+	delete(p.clients, config.BundleID)
+}
+
+// BundleIDs returns the sorted bundle IDs of the pool.
+func (p *StorePool) BundleIDs() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ids := make([]string, 0, len(p.configs))
+	for id := range p.configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Client returns the client of the bundle ID, creating it on first use.
+func (p *StorePool) Client(bundleID string) (*StoreClient, error) {
+	p.mu.RLock()
+	client, ok := p.clients[bundleID]
+	p.mu.RUnlock()
+	if ok {
+		return client, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[bundleID]; ok {
+		return client, nil
+	}
+	config, ok := p.configs[bundleID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBundleID, bundleID)
+	}
+	client = NewStoreClientWithHTTPClient(config, p.httpCli)
+	p.clients[bundleID] = client
+	return client, nil
+}
+
+// ClientForTransaction returns the client of the app of the transaction.
+func (p *StorePool) ClientForTransaction(transaction *JWSTransaction) (*StoreClient, error) {
+	return p.Client(transaction.BundleID)
+}
+
+// ClientForNotification returns the client of the app of the notification, taken from its data, summary or external purchase token.
+func (p *StorePool) ClientForNotification(notification *appstore.SubscriptionNotificationV2DecodedPayload) (*StoreClient, error) {
+	bundleID := notification.Data.BundleID
+	if bundleID == "" {
+		bundleID = notification.Summary.BundleID
+	}
+	if bundleID == "" {
+		bundleID = notification.ExternalPurchaseToken.BundleID
+	}
+	return p.Client(bundleID)
+}
+
+// DecodeJWS routes a signed payload to the client of the bundle ID it carries, which verifies and decodes it.
+// Renewal infos carry no bundle ID, they must be decoded with the client of their transaction.
+func (p *StorePool) DecodeJWS(jwsEncode string) (*StoreClient, *DecodedJWS, error) {
+	bundleID, err := jwsBundleID(jwsEncode)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := p.Client(bundleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoded, err := client.DecodeJWS(jwsEncode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, decoded, nil
+}
+
+// jwsBundleID reads the bundle ID of a transaction, app transaction or notification without verifying it.
+func jwsBundleID(jwsEncode string) (string, error) {
+	if _, err := DetectJWSPayloadType(jwsEncode); err != nil {
+		return "", err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(jwsEncode, ".")[1])
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedJWS, err)
+	}
+	var fields struct {
+		BundleID string `json:"bundleId"`
+		Data     struct {
+			BundleID string `json:"bundleId"`
+		} `json:"data"`
+		Summary struct {
+			BundleID string `json:"bundleId"`
+		} `json:"summary"`
+		ExternalPurchaseToken struct {
+			BundleID string `json:"bundleId"`
+		} `json:"externalPurchaseToken"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedJWS, err)
+	}
+	for _, id := range []string{fields.BundleID, fields.Data.BundleID, fields.Summary.BundleID, fields.ExternalPurchaseToken.BundleID} {
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no bundle id in the payload", ErrUnknownBundleID)
+}
@@ -0,0 +1,111 @@
+package api_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/apitest"
+)
+
+func TestStorePool(t *testing.T) {
+	t.Parallel()
+	servers := map[string]*apitest.Server{}
+	var configs []*api.StoreConfig
+	for _, bundleID := range []string{"com.example.one", "com.example.two"} {
+		s, err := apitest.NewServer(bundleID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(s.Close)
+		s.AddTransaction(api.JWSTransaction{TransactionID: bundleID + ".1001", ProductID: "monthly", Type: api.AutoRenewable})
+		servers[bundleID] = s
+		configs = append(configs, s.Config())
+	}
+	pool := api.NewStorePool(&http.Client{}, configs...)
+
+	var wg sync.WaitGroup
+	clients := make([]*api.StoreClient, 8)
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clients[i], _ = pool.Client("com.example.one")
+		}()
+	}
+	wg.Wait()
+	for _, c := range clients {
+		if c == nil || c != clients[0] {
+			t.Fatalf("got %p, want one shared client %p", c, clients[0])
+		}
+	}
+
+	signed, err := servers["com.example.two"].Sign(api.JWSTransaction{TransactionID: "com.example.two.1001", BundleID: "com.example.two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, decoded, err := pool.DecodeJWS(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Token.BundleID != "com.example.two" || decoded.Transaction.TransactionID != "com.example.two.1001" {
+		t.Errorf("routed to %v, decoded %+v", client.Token.BundleID, decoded.Transaction)
+	}
+	info, err := client.GetTransactionInfo(context.Background(), decoded.Transaction.TransactionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SignedTransactionInfo == "" {
+		t.Error("got empty signed transaction")
+	}
+
+	notification := &appstore.SubscriptionNotificationV2DecodedPayload{}
+	notification.Data.BundleID = "com.example.one"
+	if client, err := pool.ClientForNotification(notification); err != nil || client != clients[0] {
+		t.Errorf("got %v, %v, want the client of com.example.one", client, err)
+	}
+	if _, err := pool.ClientForTransaction(&api.JWSTransaction{BundleID: "com.example.three"}); !errors.Is(err, api.ErrUnknownBundleID) {
+		t.Errorf("got %v, want %v", err, api.ErrUnknownBundleID)
+	}
+	renewal, err := servers["com.example.one"].Sign(api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "1001", AutoRenewProductId: "monthly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pool.DecodeJWS(renewal); !errors.Is(err, api.ErrUnknownBundleID) {
+		t.Errorf("got %v, want %v", err, api.ErrUnknownBundleID)
+	}
+}
+
+func TestStorePool_DecodeJWS_MalformedX5c(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	pool := api.NewStorePool(&http.Client{}, s.Config())
+
+	payloads := map[string]string{
+		"transaction":  `{"transactionId":"1001","bundleId":"com.example.one"}`,
+		"notification": `{"notificationType":"TEST","data":{"bundleId":"com.example.one"}}`,
+	}
+	headers := map[string]string{
+		"empty x5c": `{"alg":"ES256","x5c":[]}`,
+		"short x5c": `{"alg":"ES256","x5c":["AAAA","AAAA"]}`,
+	}
+	for payloadName, payload := range payloads {
+		for headerName, header := range headers {
+			t.Run(payloadName+" "+headerName, func(t *testing.T) {
+				jws := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+				if _, _, err := pool.DecodeJWS(jws); !errors.Is(err, api.ErrMalformedJWS) {
+					t.Errorf("got %v, want %v", err, api.ErrMalformedJWS)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HistoryProductType is a productType filter of the transaction history.
+type HistoryProductType string
+
+const (
+	HistoryProductTypeAutoRenewable HistoryProductType = "AUTO_RENEWABLE"
+	HistoryProductTypeNonRenewable  HistoryProductType = "NON_RENEWABLE"
+	HistoryProductTypeConsumable    HistoryProductType = "CONSUMABLE"
+	HistoryProductTypeNonConsumable HistoryProductType = "NON_CONSUMABLE"
+)
+
+// InAppOwnershipType https://developer.apple.com/documentation/appstoreserverapi/inappownershiptype
+type InAppOwnershipType string
+
+const (
+	InAppOwnershipTypeFamilyShared InAppOwnershipType = "FAMILY_SHARED"
+	InAppOwnershipTypePurchased    InAppOwnershipType = "PURCHASED"
+)
+
+// SortOrder is the order of the transaction history.
+type SortOrder string
+
+const (
+	SortAscending  SortOrder = "ASCENDING"
+	SortDescending SortOrder = "DESCENDING"
+)
+
+// TransactionHistoryQuery filters the transaction history. The zero value matches every transaction.
+// https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history#query-parameters
+type TransactionHistoryQuery struct {
+	// StartDate excludes the transactions purchased before it when not zero.
+	StartDate time.Time
+	// EndDate excludes the transactions purchased at or after it when not zero.
+	EndDate                      time.Time
+	ProductIDs                   []string
+	ProductTypes                 []HistoryProductType
+	SubscriptionGroupIdentifiers []string
+	InAppOwnershipType           InAppOwnershipType
+	// Revoked only returns the revoked transactions when true, and excludes them when false. Every transaction is returned when nil.
+	Revoked *bool
+	// Sort is SortAscending when empty.
+	Sort SortOrder
+}
+
+// Values validates the query and encodes it for GetTransactionHistory and TransactionHistory.
+// It returns the Error the App Store would return for an invalid parameter.
+func (q TransactionHistoryQuery) Values() (*url.Values, error) {
+	values := &url.Values{}
+	if !q.StartDate.IsZero() {
+		values.Set("startDate", strconv.FormatInt(q.StartDate.UnixMilli(), 10))
+	}
+	if !q.EndDate.IsZero() {
+		if !q.StartDate.IsZero() && !q.EndDate.After(q.StartDate) {
+			return nil, StartDateAfterEndDateError
+		}
+		values.Set("endDate", strconv.FormatInt(q.EndDate.UnixMilli(), 10))
+	}
+	for _, id := range q.ProductIDs {
+		if id == "" {
+			return nil, InvalidProductIdError
+		}
+		values.Add("productId", id)
+	}
+	for _, t := range q.ProductTypes {
+		switch t {
+		case HistoryProductTypeAutoRenewable, HistoryProductTypeNonRenewable, HistoryProductTypeConsumable, HistoryProductTypeNonConsumable:
+			values.Add("productType", string(t))
+		default:
+			return nil, InvalidProductTypeError
+		}
+	}
+	for _, id := range q.SubscriptionGroupIdentifiers {
+		if id == "" {
+			return nil, InvalidSubscriptionGroupIdentifierError
+		}
+		values.Add("subscriptionGroupIdentifier", id)
+	}
+	switch q.InAppOwnershipType {
+	case "":
+	case InAppOwnershipTypeFamilyShared, InAppOwnershipTypePurchased:
+		values.Set("inAppOwnershipType", string(q.InAppOwnershipType))
+	default:
+		return nil, InvalidInAppOwnershipTypeError
+	}
+	if q.Revoked != nil {
+		values.Set("revoked", strconv.FormatBool(*q.Revoked))
+	}
+	switch q.Sort {
+	case "":
+	case SortAscending, SortDescending:
+		values.Set("sort", string(q.Sort))
+	default:
+		return nil, InvalidSortError
+	}
+	return values, nil
+}
+
+// SubscriptionStatusQuery filters the subscription statuses. The zero value matches every status.
+// https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses#query-parameters
+type SubscriptionStatusQuery struct {
+	Statuses []AutoRenewSubscriptionStatus
+}
+
+// Values validates the query and encodes it for GetALLSubscriptionStatuses.
+// It returns the Error the App Store would return for an invalid parameter.
+func (q SubscriptionStatusQuery) Values() (*url.Values, error) {
+	values := &url.Values{}
+	for _, status := range q.Statuses {
+		if status < SubscriptionActive || status > SubscriptionRevoked {
+			return nil, InvalidStatusError
+		}
+		values.Add("status", strconv.Itoa(int(status)))
+	}
+	return values, nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransactionHistoryQuery_Values(t *testing.T) {
+	t.Parallel()
+	start := time.UnixMilli(1700000000000)
+	revoked := false
+
+	tests := []struct {
+		name    string
+		query   TransactionHistoryQuery
+		want    string
+		wantErr error
+	}{
+		{name: "zero", want: ""},
+		{name: "all", query: TransactionHistoryQuery{
+			StartDate:                    start,
+			EndDate:                      start.Add(time.Hour),
+			ProductIDs:                   []string{"monthly", "yearly"},
+			ProductTypes:                 []HistoryProductType{HistoryProductTypeAutoRenewable},
+			SubscriptionGroupIdentifiers: []string{"21000000"},
+			InAppOwnershipType:           InAppOwnershipTypePurchased,
+			Revoked:                      &revoked,
+			Sort:                         SortDescending,
+		}, want: "endDate=1700003600000&inAppOwnershipType=PURCHASED&productId=monthly&productId=yearly&productType=AUTO_RENEWABLE&revoked=false&sort=DESCENDING&startDate=1700000000000&subscriptionGroupIdentifier=21000000"},
+		{name: "end before start", query: TransactionHistoryQuery{StartDate: start, EndDate: start}, wantErr: StartDateAfterEndDateError},
+		{name: "product type", query: TransactionHistoryQuery{ProductTypes: []HistoryProductType{"Auto-Renewable Subscription"}}, wantErr: InvalidProductTypeError},
+		{name: "product id", query: TransactionHistoryQuery{ProductIDs: []string{""}}, wantErr: InvalidProductIdError},
+		{name: "ownership", query: TransactionHistoryQuery{InAppOwnershipType: "SHARED"}, wantErr: InvalidInAppOwnershipTypeError},
+		{name: "sort", query: TransactionHistoryQuery{Sort: "desc"}, wantErr: InvalidSortError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.Values()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Encode() != tt.want {
+				t.Errorf("got %v, want %v", got.Encode(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionStatusQuery_Values(t *testing.T) {
+	t.Parallel()
+	got, err := SubscriptionStatusQuery{Statuses: []AutoRenewSubscriptionStatus{SubscriptionActive, SubscriptionGracePeriod}}.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Encode() != "status=1&status=4" {
+		t.Errorf("got %v, want status=1&status=4", got.Encode())
+	}
+	if _, err := (SubscriptionStatusQuery{Statuses: []AutoRenewSubscriptionStatus{0}}).Values(); !errors.Is(err, InvalidStatusError) {
+		t.Errorf("got %v, want %v", err, InvalidStatusError)
+	}
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Default values of RetryPolicy.
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryBaseDelay   = 500 * time.Millisecond
+	DefaultRetryMaxDelay    = 30 * time.Second
+)
+
+// RetryPolicy retries the App Store Server API calls of StoreClient.Do.
+//
+// A GET request is retried after a transport error, a 5xx status, a 429 status or a retryable Error,
+// waiting for the Retry-After header when the response has one, else backing off exponentially with jitter.
+// The other methods, such as ExtendSubscriptionRenewalDate or SendConsumptionInformation, may change something twice
+// when the App Store received them, so they are only retried on 429 or when the connection could not be made.
+// WithRetryNonIdempotent opts a call into the retries of GET.
+// https://developer.apple.com/documentation/appstoreserverapi/identifying_rate_limits
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one, DefaultRetryMaxAttempts when zero.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on each retry, DefaultRetryBaseDelay when zero.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay, DefaultRetryMaxDelay when zero.
+	// A Retry-After longer than MaxDelay is not waited for, the error is returned instead.
+	MaxDelay time.Duration
+
+	// OnAttempt is called after each attempt, when not nil.
+	OnAttempt func(attempt RetryAttempt)
+	// OnRetry is called before waiting for a retry, when not nil.
+	OnRetry func(attempt RetryAttempt, delay time.Duration)
+}
+
+// RetryAttempt describes an attempt of a request, passed to the metrics hooks of RetryPolicy.
+type RetryAttempt struct {
+	Method string
+	URL    string
+	// Attempt is 1 for the first attempt.
+	Attempt    int
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts == 0 {
+		return DefaultRetryMaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) baseDelay() time.Duration {
+	if p.BaseDelay == 0 {
+		return DefaultRetryBaseDelay
+	}
+	return p.BaseDelay
+}
+
+func (p *RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay == 0 {
+		return DefaultRetryMaxDelay
+	}
+	return p.MaxDelay
+}
+
+// backoff returns the delay before the retry following attempt, between half and all of the exponential delay.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.baseDelay()
+	for i := 1; i < attempt && d < p.maxDelay(); i++ {
+		d *= 2
+	}
+	d = min(d, p.maxDelay())
+	return d/2 + rand.N(d/2+1)
+}
+
+type retryNonIdempotentKey struct{}
+
+// WithRetryNonIdempotent returns a context retrying the requests of any method like GET,
+// for the calls the caller knows to be safe to send twice.
+func WithRetryNonIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryNonIdempotentKey{}, true)
+}
+
+func retryNonIdempotent(ctx context.Context) bool {
+	v, _ := ctx.Value(retryNonIdempotentKey{}).(bool)
+	return v
+}
+
+// retryable reports whether the outcome of a request of method may be retried.
+// idempotent is true for GET requests and for the calls opted in with WithRetryNonIdempotent.
+func retryable(idempotent bool, statusCode int, err error) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !idempotent {
+		return notSent(err)
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Retryable() {
+		return true
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return true
+	}
+	// transport errors have no status code
+	return statusCode == 0 && err != nil
+}
+
+// notSent reports whether err happened before the request was sent, while connecting to the App Store.
+func notSent(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryAfter returns the delay requested by the Retry-After header, which is either seconds,
+// a UNIX time in milliseconds or an HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n > 1e12 {
+			return max(time.UnixMilli(n).Sub(now), 0), true
+		}
+		return time.Duration(n) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// do sends the request with send until it succeeds, is not retryable or the attempts are exhausted.
+func (p *RetryPolicy) do(ctx context.Context, method, url string, body io.Reader,
+	send func(ctx context.Context, method, url string, body io.Reader) (int, http.Header, []byte, error)) (int, []byte, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	idempotent := method == http.MethodGet || retryNonIdempotent(ctx)
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(payload)
+		}
+		start := time.Now()
+		statusCode, header, respBody, err := send(ctx, method, url, reader)
+		info := RetryAttempt{Method: method, URL: url, Attempt: attempt, StatusCode: statusCode, Err: err, Duration: time.Since(start)}
+		if p.OnAttempt != nil {
+			p.OnAttempt(info)
+		}
+
+		if err == nil && statusCode < 400 || attempt >= p.maxAttempts() || ctx.Err() != nil || !retryable(idempotent, statusCode, err) {
+			return statusCode, respBody, err
+		}
+		delay, ok := retryAfter(header, time.Now())
+		if ok && delay > p.maxDelay() {
+			return statusCode, respBody, err
+		}
+		if !ok {
+			delay = p.backoff(attempt)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return statusCode, respBody, err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(info, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return statusCode, respBody, err
+		case <-timer.C:
+		}
+	}
+}
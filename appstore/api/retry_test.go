@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRetryTestClient(t *testing.T, policy *RetryPolicy, handler http.HandlerFunc) *StoreClient {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewStoreClientWithHTTPClient(&StoreConfig{
+		KeyContent:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		KeyID:       "KEYID",
+		BundleID:    "com.example.app",
+		Issuer:      "issuer",
+		HostDebug:   srv.URL,
+		RetryPolicy: policy,
+	}, srv.Client())
+}
+
+func TestStoreClient_Do_Retry(t *testing.T) {
+	t.Parallel()
+	rateLimited := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"errorCode":4290000,"errorMessage":"Rate limit exceeded."}`))
+	}
+	retryableError := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"errorCode":5000001,"errorMessage":"An unknown error occurred. Please try again."}`))
+	}
+	notFound := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		failures     []func(w http.ResponseWriter)
+		optIn        bool
+		wantAttempts int32
+		wantStatus   int
+	}{
+		{name: "success", method: http.MethodGet, wantAttempts: 1, wantStatus: http.StatusOK},
+		{name: "rate limited", method: http.MethodGet, failures: []func(http.ResponseWriter){rateLimited, rateLimited}, wantAttempts: 3, wantStatus: http.StatusOK},
+		{name: "retryable error", method: http.MethodGet, failures: []func(http.ResponseWriter){retryableError}, wantAttempts: 2, wantStatus: http.StatusOK},
+		{name: "put server error", method: http.MethodPut, failures: []func(http.ResponseWriter){retryableError}, wantAttempts: 1, wantStatus: http.StatusInternalServerError},
+		{name: "put server error opted in", method: http.MethodPut, optIn: true, failures: []func(http.ResponseWriter){retryableError}, wantAttempts: 2, wantStatus: http.StatusOK},
+		{name: "put rate limited", method: http.MethodPut, failures: []func(http.ResponseWriter){rateLimited}, wantAttempts: 2, wantStatus: http.StatusOK},
+		{name: "attempts exhausted", method: http.MethodGet, failures: []func(http.ResponseWriter){retryableError, retryableError, retryableError}, wantAttempts: 3, wantStatus: http.StatusInternalServerError},
+		{name: "not retryable", method: http.MethodGet, failures: []func(http.ResponseWriter){notFound}, wantAttempts: 1, wantStatus: http.StatusNotFound},
+		{name: "post server error", method: http.MethodPost, failures: []func(http.ResponseWriter){retryableError}, wantAttempts: 1, wantStatus: http.StatusInternalServerError},
+		{name: "post rate limited", method: http.MethodPost, failures: []func(http.ResponseWriter){rateLimited}, wantAttempts: 2, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var attempts, hooks int32
+			policy := &RetryPolicy{
+				BaseDelay: time.Millisecond,
+				OnAttempt: func(attempt RetryAttempt) { atomic.AddInt32(&hooks, 1) },
+			}
+			client := newRetryTestClient(t, policy, func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				if body, _ := io.ReadAll(r.Body); r.Method != http.MethodGet && string(body) != `{"requestIdentifier":"id"}` {
+					t.Errorf("got body %q on attempt %d", body, n)
+				}
+				if int(n) <= len(tt.failures) {
+					tt.failures[n-1](w)
+					return
+				}
+				_, _ = w.Write([]byte(`{}`))
+			})
+
+			var body io.Reader
+			if tt.method != http.MethodGet {
+				body = strings.NewReader(`{"requestIdentifier":"id"}`)
+			}
+			ctx := context.Background()
+			if tt.optIn {
+				ctx = WithRetryNonIdempotent(ctx)
+			}
+			statusCode, _, _ := client.Do(ctx, tt.method, client.host+"/inApps/v1/test", body)
+			if statusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", statusCode, tt.wantStatus)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if hooks != tt.wantAttempts {
+				t.Errorf("got %d OnAttempt calls, want %d", hooks, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestStoreClient_Do_RetryDeadline(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	client := newRetryTestClient(t, &RetryPolicy{}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"errorCode":4290000,"errorMessage":"Rate limit exceeded."}`))
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := client.Do(ctx, http.MethodGet, client.host+"/inApps/v1/test", nil)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter() != 10 {
+		t.Errorf("got %v, want a rate limit error", err)
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want 1", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waited %v beyond the deadline", elapsed)
+	}
+}
+
+func TestStoreClient_Do_PutTimeout(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	client := newRetryTestClient(t, &RetryPolicy{BaseDelay: time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		time.Sleep(200 * time.Millisecond)
+	})
+	client.httpCli.Timeout = 50 * time.Millisecond
+
+	_, _, err := client.Do(context.Background(), http.MethodPut, client.host+"/inApps/v1/test", strings.NewReader(`{}`))
+	if err == nil {
+		t.Fatal("got nil, want a timeout")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("got %d attempts, want 1", n)
+	}
+}
+
+func TestStoreClient_Do_PutConnectionRefused(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	client := newRetryTestClient(t, &RetryPolicy{
+		BaseDelay: time.Millisecond,
+		OnAttempt: func(RetryAttempt) { atomic.AddInt32(&attempts, 1) },
+	}, func(w http.ResponseWriter, r *http.Request) {})
+	// nothing listens on the port of a closed server
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	if _, _, err := client.Do(context.Background(), http.MethodPut, srv.URL+"/inApps/v1/test", strings.NewReader(`{}`)); err == nil {
+		t.Fatal("got nil, want a connection error")
+	}
+	if n := atomic.LoadInt32(&attempts); n != DefaultRetryMaxAttempts {
+		t.Errorf("got %d attempts, want %d", n, DefaultRetryMaxAttempts)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", wantOK: false},
+		{value: "3", want: 3 * time.Second, wantOK: true},
+		{value: "1704067205000", want: 5 * time.Second, wantOK: true},
+		{value: "Mon, 01 Jan 2024 00:00:10 GMT", want: 10 * time.Second, wantOK: true},
+		{value: "soon", wantOK: false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("Retry-After", tt.value)
+		got, ok := retryAfter(header, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("retryAfter(%q) got %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
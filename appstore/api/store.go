@@ -7,7 +7,6 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,6 +16,8 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/awa/go-iap/appstore/chain"
 )
 
 const (
@@ -40,16 +41,23 @@ const (
 )
 
 type StoreConfig struct {
-	KeyContent         []byte       // Loads a .p8 certificate
-	KeyID              string       // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
-	BundleID           string       // Your app’s bundle ID
-	Issuer             string       // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
-	Sandbox            bool         // default is Production
-	TokenIssuedAtFunc  func() int64 // The token’s creation time func. Default is current timestamp.
-	TokenExpiredAtFunc func() int64 // The token’s expiration time func. Default is one hour later.
+	KeyContent         []byte          // Loads a .p8 certificate
+	KeyID              string          // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
+	BundleID           string          // Your app’s bundle ID
+	Issuer             string          // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
+	Sandbox            bool            // default is Production
+	TokenIssuedAtFunc  func() int64    // The token’s creation time func. Default is current timestamp.
+	TokenExpiredAtFunc func() int64    // The token’s expiration time func. Default is one hour later.
+	ChainVerifier      *chain.Verifier // Verifies the x5c chain of signed data. Default trusts the Apple Root CA - G3 without revocation checks.
+	RetryPolicy        *RetryPolicy    // Retries the failed requests. Default is no retry.
+	KeyProvider        KeyProvider     // Supplies KeyID and KeyContent on demand, replacing them when set.
+	KeyCacheDuration   time.Duration   // How long the key of the KeyProvider is used before asking again. Default is DefaultKeyCacheDuration.
+	KeyErrorLog        func(err error) // Receives the KeyProvider failures and invalid keys while the current key keeps signing. Default drops them.
+	SandboxFallback    bool            // Looks up the transactions and orders not found in Production in the Sandbox too.
 
 	// internal variables
-	HostDebug string // can be used to override the host for testing
+	HostDebug        string // can be used to override the host for testing
+	HostSandboxDebug string // can be used to override the host of the SandboxFallback for testing
 }
 
 type (
@@ -108,6 +116,10 @@ type StoreClient struct {
 	httpCli *http.Client
 	cert    *Cert
 	host    string
+	retry   *RetryPolicy
+
+	environment Environment
+	sandboxHost string // set when the lookups fall back to the Sandbox
 }
 
 // NewStoreClient create a appstore server api client
@@ -117,12 +129,14 @@ func NewStoreClient(config *StoreConfig) *StoreClient {
 
 	client := &StoreClient{
 		Token: token,
-		cert:  &Cert{},
+		cert:  &Cert{Verifier: config.ChainVerifier},
 		httpCli: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		host: getHost(config.Sandbox, config.HostDebug),
+		host:  getHost(config.Sandbox, config.HostDebug),
+		retry: config.RetryPolicy,
 	}
+	client.environment, client.sandboxHost = environments(config)
 	return client
 }
 
@@ -133,10 +147,12 @@ func NewStoreClientWithHTTPClient(config *StoreConfig, httpClient *http.Client)
 
 	client := &StoreClient{
 		Token:   token,
-		cert:    &Cert{},
+		cert:    &Cert{Verifier: config.ChainVerifier},
 		httpCli: httpClient,
 		host:    getHost(config.Sandbox, config.HostDebug),
+		retry:   config.RetryPolicy,
 	}
+	client.environment, client.sandboxHost = environments(config)
 	return client
 }
 
@@ -151,8 +167,19 @@ func getHost(sandbox bool, debugHost string) string {
 }
 
 // GetALLSubscriptionStatuses https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
+// Transactions not found in Production are looked up in the Sandbox when the SandboxFallback of the StoreConfig is set.
 func (a *StoreClient) GetALLSubscriptionStatuses(ctx context.Context, originalTransactionId string, query *url.Values) (rsp *StatusResponse, err error) {
-	URL := a.host + PathGetALLSubscriptionStatus
+	rsp, env, err := sandboxFallback(a, isNotFound, func(host string) (*StatusResponse, error) {
+		return a.getALLSubscriptionStatuses(ctx, host, originalTransactionId, query)
+	})
+	if rsp != nil {
+		rsp.Environment = env
+	}
+	return rsp, err
+}
+
+func (a *StoreClient) getALLSubscriptionStatuses(ctx context.Context, host string, originalTransactionId string, query *url.Values) (rsp *StatusResponse, err error) {
+	URL := host + PathGetALLSubscriptionStatus
 	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
 	if query != nil {
 		URL = URL + "?" + query.Encode()
@@ -175,8 +202,19 @@ func (a *StoreClient) GetALLSubscriptionStatuses(ctx context.Context, originalTr
 }
 
 // LookupOrderID https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
+// Orders not found in Production are looked up in the Sandbox when the SandboxFallback of the StoreConfig is set.
 func (a *StoreClient) LookupOrderID(ctx context.Context, orderId string) (rsp *OrderLookupResponse, err error) {
-	URL := a.host + PathLookUp
+	rsp, env, err := sandboxFallback(a, isOrderNotFound, func(host string) (*OrderLookupResponse, error) {
+		return a.lookupOrderID(ctx, host, orderId)
+	})
+	if rsp != nil {
+		rsp.Environment = env
+	}
+	return rsp, err
+}
+
+func (a *StoreClient) lookupOrderID(ctx context.Context, host string, orderId string) (rsp *OrderLookupResponse, err error) {
+	URL := host + PathLookUp
 	URL = strings.Replace(URL, "{orderId}", orderId, -1)
 	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
 	if err != nil {
@@ -196,8 +234,20 @@ func (a *StoreClient) LookupOrderID(ctx context.Context, orderId string) (rsp *O
 }
 
 // GetTransactionHistory https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
+// It loads every page in memory, TransactionHistory iterates over them instead.
+// Transactions not found in Production are looked up in the Sandbox when the SandboxFallback of the StoreConfig is set.
 func (a *StoreClient) GetTransactionHistory(ctx context.Context, transactionId string, query *url.Values) (responses []*HistoryResponse, err error) {
-	URL := a.host + PathTransactionHistory
+	responses, env, err := sandboxFallback(a, isNotFound, func(host string) ([]*HistoryResponse, error) {
+		return a.getTransactionHistory(ctx, host, transactionId, query)
+	})
+	for _, rsp := range responses {
+		rsp.Environment = env
+	}
+	return responses, err
+}
+
+func (a *StoreClient) getTransactionHistory(ctx context.Context, host string, transactionId string, query *url.Values) (responses []*HistoryResponse, err error) {
+	URL := host + PathTransactionHistory
 	URL = strings.Replace(URL, "{transactionId}", transactionId, -1)
 
 	if query == nil {
@@ -237,8 +287,19 @@ func (a *StoreClient) GetTransactionHistory(ctx context.Context, transactionId s
 }
 
 // GetTransactionInfo https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
+// Transactions not found in Production are looked up in the Sandbox when the SandboxFallback of the StoreConfig is set.
 func (a *StoreClient) GetTransactionInfo(ctx context.Context, transactionId string) (rsp *TransactionInfoResponse, err error) {
-	URL := a.host + PathTransactionInfo
+	rsp, env, err := sandboxFallback(a, isNotFound, func(host string) (*TransactionInfoResponse, error) {
+		return a.getTransactionInfo(ctx, host, transactionId)
+	})
+	if rsp != nil {
+		rsp.Environment = env
+	}
+	return rsp, err
+}
+
+func (a *StoreClient) getTransactionInfo(ctx context.Context, host string, transactionId string) (rsp *TransactionInfoResponse, err error) {
+	URL := host + PathTransactionInfo
 	URL = strings.Replace(URL, "{transactionId}", transactionId, -1)
 
 	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
@@ -259,6 +320,7 @@ func (a *StoreClient) GetTransactionInfo(ctx context.Context, transactionId stri
 }
 
 // GetRefundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
+// It loads every page in memory, RefundHistory iterates over them instead.
 func (a *StoreClient) GetRefundHistory(ctx context.Context, originalTransactionId string) (responses []*RefundLookupResponse, err error) {
 	baseURL := a.host + PathRefundHistory
 	baseURL = strings.Replace(baseURL, "{originalTransactionId}", originalTransactionId, -1)
@@ -374,6 +436,7 @@ func (a *StoreClient) GetSubscriptionRenewalDataStatus(ctx context.Context, prod
 }
 
 // GetAllNotificationHistory returns all the NotificationHistoryResponseItem using the paginationToken on behalf of you.
+// It loads every page in memory, NotificationHistory iterates over them instead.
 func (a *StoreClient) GetAllNotificationHistory(ctx context.Context, body NotificationHistoryRequest, duration time.Duration) (responses []NotificationHistoryResponseItem, err error) {
 	paginationToken := ""
 	for {
@@ -463,6 +526,7 @@ func (a *StoreClient) SetAppAccountToken(ctx context.Context, originalTransactio
 
 // ParseSignedTransactions parse the jws singed transactions
 // Per doc: https://datatracker.ietf.org/doc/html/rfc7515#section-4.1.6
+// Transactions failing verification are dropped, the Decoded methods such as GetTransactionHistoryDecoded report them instead.
 func (a *StoreClient) ParseSignedTransactions(transactions []string) ([]*JWSTransaction, error) {
 	result := make([]*JWSTransaction, 0)
 	for _, v := range transactions {
@@ -476,31 +540,20 @@ func (a *StoreClient) ParseSignedTransactions(transactions []string) ([]*JWSTran
 }
 
 // ParseJWSEncodeString parse the jws encode string, such as JWSTransaction and JWSRenewalInfoDecodedPayload
+// It returns a *JWSTransaction, *JWSRenewalInfoDecodedPayload, *AppTransaction or *appstore.SubscriptionNotificationV2DecodedPayload,
+// see DecodeJWS for the detected type.
 func (a *StoreClient) ParseJWSEncodeString(jwsEncode string) (interface{}, error) {
-	// Split the JWS format string into its three parts
-	parts := strings.Split(jwsEncode, ".")
-
-	// Decode the payload part of the JWS format string
-	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	decoded, err := a.DecodeJWS(jwsEncode)
 	if err != nil {
 		return nil, err
 	}
-
-	// Determine which struct to use based on the payload contents
-	if strings.Contains(string(payload), "transactionId") {
-		transaction := &JWSTransaction{}
-		err = a.parseJWS(jwsEncode, transaction)
-		return transaction, err
-	} else if strings.Contains(string(payload), "renewalDate") {
-		renewalInfo := &JWSRenewalInfoDecodedPayload{}
-		err = a.parseJWS(jwsEncode, renewalInfo)
-		return renewalInfo, err
-	}
-
-	return nil, nil
+	return decoded.Value(), nil
 }
 
-func (a *StoreClient) parseJWS(jwsEncode string, claims jwt.Claims) error {
+func (a *StoreClient) parseJWS(ctx context.Context, jwsEncode string, claims jwt.Claims) error {
+	if err := a.cert.checkX5c(jwsEncode); err != nil {
+		return err
+	}
 	rootCertBytes, err := a.cert.extractCertByIndex(jwsEncode, 2)
 	if err != nil {
 		return err
@@ -527,7 +580,7 @@ func (a *StoreClient) parseJWS(jwsEncode string, claims jwt.Claims) error {
 	if err != nil {
 		return fmt.Errorf("appstore failed to parse leaf certificate")
 	}
-	if err = a.cert.verifyCert(rootCert, intermediaCert, leafCert); err != nil {
+	if err = a.cert.verifyCert(ctx, rootCert, intermediaCert, leafCert, chain.SignedDate(jwsEncode)); err != nil {
 		return err
 	}
 
@@ -538,15 +591,19 @@ func (a *StoreClient) parseJWS(jwsEncode string, claims jwt.Claims) error {
 
 	_, err = jwt.ParseWithClaims(jwsEncode, claims, func(token *jwt.Token) (interface{}, error) {
 		return pk, nil
-	})
+	}, jwt.WithTimeFunc(a.cert.verifier().CurrentTime))
 	return err
 }
 
 // ParseSignedTransaction parse one jws singed transaction for API like GetTransactionInfo
 func (a *StoreClient) ParseSignedTransaction(transaction string) (*JWSTransaction, error) {
+	return a.parseSignedTransaction(context.Background(), transaction)
+}
+
+func (a *StoreClient) parseSignedTransaction(ctx context.Context, transaction string) (*JWSTransaction, error) {
 	tran := &JWSTransaction{}
 
-	err := a.parseJWS(transaction, tran)
+	err := a.parseJWS(ctx, transaction, tran)
 	if err != nil {
 		return nil, err
 	}
@@ -555,15 +612,25 @@ func (a *StoreClient) ParseSignedTransaction(transaction string) (*JWSTransactio
 }
 
 // Do Per doc: https://developer.apple.com/documentation/appstoreserverapi#topics
+// The request is retried according to the RetryPolicy of the StoreConfig.
 func (a *StoreClient) Do(ctx context.Context, method string, url string, body io.Reader) (int, []byte, error) {
-	authToken, err := a.Token.GenerateIfExpired()
+	if a.retry != nil {
+		return a.retry.do(ctx, method, url, body, a.do)
+	}
+	statusCode, _, bodyBytes, err := a.do(ctx, method, url, body)
+	return statusCode, bodyBytes, err
+}
+
+func (a *StoreClient) do(ctx context.Context, method string, url string, body io.Reader) (int, http.Header, []byte, error) {
+	authToken, release, err := a.Token.Acquire(ctx)
 	if err != nil {
-		return 0, nil, fmt.Errorf("appstore generate token err %w", err)
+		return 0, nil, nil, fmt.Errorf("appstore generate token err %w", err)
 	}
+	defer release()
 
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
-		return 0, nil, fmt.Errorf("appstore new http request err %w", err)
+		return 0, nil, nil, fmt.Errorf("appstore new http request err %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -573,20 +640,20 @@ func (a *StoreClient) Do(ctx context.Context, method string, url string, body io
 
 	resp, err := a.httpCli.Do(req)
 	if err != nil {
-		return 0, nil, fmt.Errorf("appstore http client do err %w", err)
+		return 0, nil, nil, fmt.Errorf("appstore http client do err %w", err)
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return resp.StatusCode, nil, fmt.Errorf("appstore read http body err %w", err)
+		return resp.StatusCode, resp.Header, nil, fmt.Errorf("appstore read http body err %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		if rErr, ok := newAppStoreAPIError(bodyBytes, resp.Header); ok {
-			return resp.StatusCode, bodyBytes, rErr
+			return resp.StatusCode, resp.Header, bodyBytes, rErr
 		}
 	}
 
-	return resp.StatusCode, bodyBytes, err
+	return resp.StatusCode, resp.Header, bodyBytes, err
 }
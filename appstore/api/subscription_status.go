@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+// PriceIncreaseConsent is the consent of the customer to a price increase, from the priceIncreaseStatus of the renewal info.
+// https://developer.apple.com/documentation/appstoreserverapi/priceincreasestatus
+type PriceIncreaseConsent int
+
+// list of PriceIncreaseConsent
+const (
+	// PriceIncreaseNone means there is no price increase.
+	PriceIncreaseNone PriceIncreaseConsent = iota
+	// PriceIncreasePending means the customer has not responded to the price increase yet.
+	PriceIncreasePending
+	// PriceIncreaseAccepted means the customer consented to the price increase, or it does not require consent.
+	PriceIncreaseAccepted
+)
+
+// PlanChange is the change of product at the next renewal, when the autoRenewProductId differs from the productId.
+// Upgrades take effect immediately, so a pending change is usually a downgrade or a crossgrade.
+type PlanChange string
+
+// list of PlanChange
+const (
+	PlanChangeNone       PlanChange = ""
+	PlanChangeUpgrade    PlanChange = "upgrade"
+	PlanChangeDowngrade  PlanChange = "downgrade"
+	PlanChangeCrossgrade PlanChange = "crossgrade"
+	// PlanChangeUnknown means the level of one of the products is unknown.
+	PlanChangeUnknown PlanChange = "unknown"
+)
+
+// SubscriptionGroupStatus is what the customer has in a subscription group.
+// Time fields are the zero time when the App Store does not provide them.
+type SubscriptionGroupStatus struct {
+	SubscriptionGroupIdentifier string
+	OriginalTransactionId       string
+	Status                      AutoRenewSubscriptionStatus
+	// Entitled tells whether the customer has access to ProductID, when the subscription is active or in the grace period.
+	Entitled               bool
+	ProductID              string
+	ExpiresDate            time.Time
+	GracePeriodExpiresDate time.Time
+	InBillingRetry         bool
+	AutoRenew              bool
+	// AutoRenewProductID is the product the subscription renews to.
+	AutoRenewProductID string
+	PlanChange         PlanChange
+	PriceIncrease      PriceIncreaseConsent
+
+	Transaction *JWSTransaction
+	// RenewalInfo is nil when the App Store sent none.
+	RenewalInfo *JWSRenewalInfoDecodedPayload
+}
+
+// GetSubscriptionGroupStatuses gets the statuses of all the subscriptions of the customer, and resolves each subscription group.
+// See ResolveSubscriptionGroups for levels and the errors.
+func (a *StoreClient) GetSubscriptionGroupStatuses(ctx context.Context, originalTransactionId string, levels map[string]int) ([]SubscriptionGroupStatus, error) {
+	rsp, err := a.GetALLSubscriptionStatusesDecoded(ctx, originalTransactionId, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveSubscriptionGroups(rsp, levels, time.Now())
+}
+
+// ResolveSubscriptionGroups resolves what the customer has in each subscription group as of now.
+// When a group has several subscriptions, e.g. with Family Sharing, the one granting the most access is kept.
+// levels is the level of each product in its group as in App Store Connect, 1 being the highest. It tells
+// the PlanChange apart, every change is PlanChangeUnknown when it is nil.
+// Items failing verification are left out and their errors joined, along with the groups resolved from the others.
+func ResolveSubscriptionGroups(rsp *DecodedStatusResponse, levels map[string]int, now time.Time) ([]SubscriptionGroupStatus, error) {
+	var errs []error
+	groups := make([]SubscriptionGroupStatus, 0, len(rsp.Data))
+	for _, group := range rsp.Data {
+		var resolved *SubscriptionGroupStatus
+		for _, item := range group.LastTransactions {
+			if item.Transaction.Err != nil {
+				errs = append(errs, fmt.Errorf("transaction of %s: %w", item.OriginalTransactionId, item.Transaction.Err))
+				continue
+			}
+			if item.RenewalInfo.Signed != "" && item.RenewalInfo.Err != nil {
+				errs = append(errs, fmt.Errorf("renewal info of %s: %w", item.OriginalTransactionId, item.RenewalInfo.Err))
+				continue
+			}
+			status := resolveSubscription(group.SubscriptionGroupIdentifier, item, levels, now)
+			if resolved == nil || status.outranks(resolved) {
+				resolved = &status
+			}
+		}
+		if resolved != nil {
+			groups = append(groups, *resolved)
+		}
+	}
+	return groups, errors.Join(errs...)
+}
+
+func resolveSubscription(groupIdentifier string, item DecodedLastTransactionsItem, levels map[string]int, now time.Time) SubscriptionGroupStatus {
+	tx, renewal := item.Transaction.Transaction, item.RenewalInfo.RenewalInfo
+	state := tx.Entitlement(now, renewal).State
+	s := SubscriptionGroupStatus{
+		SubscriptionGroupIdentifier: groupIdentifier,
+		OriginalTransactionId:       item.OriginalTransactionId,
+		Status:                      item.Status,
+		Entitled:                    state == entitlement.StateActive || state == entitlement.StateGracePeriod,
+		ProductID:                   tx.ProductID,
+		ExpiresDate:                 entitlement.FromMillis(tx.ExpiresDate),
+		Transaction:                 tx,
+		RenewalInfo:                 renewal,
+	}
+	if renewal == nil {
+		return s
+	}
+
+	s.GracePeriodExpiresDate = entitlement.FromMillis(renewal.GracePeriodExpiresDate)
+	s.InBillingRetry = renewal.IsInBillingRetryPeriod != nil && *renewal.IsInBillingRetryPeriod
+	s.AutoRenew = renewal.AutoRenewStatus == AutoRenewStatusOn
+	s.AutoRenewProductID = renewal.AutoRenewProductId
+	if s.AutoRenewProductID != "" && s.AutoRenewProductID != s.ProductID {
+		s.PlanChange = planChange(levels, s.ProductID, s.AutoRenewProductID)
+	}
+	if renewal.PriceIncreaseStatus != nil {
+		s.PriceIncrease = PriceIncreasePending
+		if *renewal.PriceIncreaseStatus == PriceIncreaseStatusConsented {
+			s.PriceIncrease = PriceIncreaseAccepted
+		}
+	}
+	return s
+}
+
+func planChange(levels map[string]int, from, to string) PlanChange {
+	fromLevel, ok := levels[from]
+	if !ok {
+		return PlanChangeUnknown
+	}
+	toLevel, ok := levels[to]
+	if !ok {
+		return PlanChangeUnknown
+	}
+	switch {
+	case toLevel < fromLevel:
+		return PlanChangeUpgrade
+	case toLevel > fromLevel:
+		return PlanChangeDowngrade
+	default:
+		return PlanChangeCrossgrade
+	}
+}
+
+// outranks reports whether s grants more than other: access first, then the later expiry.
+func (s *SubscriptionGroupStatus) outranks(other *SubscriptionGroupStatus) bool {
+	if s.Entitled != other.Entitled {
+		return s.Entitled
+	}
+	return s.ExpiresDate.After(other.ExpiresDate)
+}
@@ -0,0 +1,106 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/apitest"
+)
+
+func TestStoreClient_GetSubscriptionGroupStatuses(t *testing.T) {
+	t.Parallel()
+	s, err := apitest.NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	now := time.Now()
+	retry := true
+	pending := api.PriceIncreaseStatusNotConsented
+	s.AddTransaction(api.JWSTransaction{
+		TransactionID:               "2001",
+		ProductID:                   "premium",
+		SubscriptionGroupIdentifier: "group",
+		Type:                        api.AutoRenewable,
+		ExpiresDate:                 now.Add(-time.Hour).UnixMilli(),
+	})
+	s.SetRenewalInfo(api.JWSRenewalInfoDecodedPayload{
+		OriginalTransactionId:  "2001",
+		ProductId:              "premium",
+		AutoRenewProductId:     "basic",
+		AutoRenewStatus:        api.AutoRenewStatusOn,
+		GracePeriodExpiresDate: now.Add(time.Hour).UnixMilli(),
+		IsInBillingRetryPeriod: &retry,
+		PriceIncreaseStatus:    &pending,
+	})
+
+	groups, err := s.Client().GetSubscriptionGroupStatuses(context.Background(), "2001", map[string]int{"premium": 1, "basic": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	got := groups[0]
+	if got.SubscriptionGroupIdentifier != "group" || got.Status != api.SubscriptionGracePeriod || !got.Entitled || got.ProductID != "premium" {
+		t.Errorf("unexpected group %+v", got)
+	}
+	if !got.InBillingRetry || got.GracePeriodExpiresDate.IsZero() || !got.AutoRenew {
+		t.Errorf("unexpected renewal state %+v", got)
+	}
+	if got.AutoRenewProductID != "basic" || got.PlanChange != api.PlanChangeDowngrade || got.PriceIncrease != api.PriceIncreasePending {
+		t.Errorf("got %v to %v with %v, want a downgrade to basic pending consent", got.PlanChange, got.AutoRenewProductID, got.PriceIncrease)
+	}
+}
+
+func TestResolveSubscriptionGroups(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	accepted := api.PriceIncreaseStatusConsented
+	transaction := func(productID string, expires time.Time) api.DecodedTransaction {
+		return api.DecodedTransaction{Signed: "signed", Transaction: &api.JWSTransaction{ProductID: productID, ExpiresDate: expires.UnixMilli()}}
+	}
+	renewal := func(productID, autoRenewProductID string) api.DecodedRenewalInfo {
+		return api.DecodedRenewalInfo{Signed: "signed", RenewalInfo: &api.JWSRenewalInfoDecodedPayload{
+			ProductId:           productID,
+			AutoRenewProductId:  autoRenewProductID,
+			AutoRenewStatus:     api.AutoRenewStatusOn,
+			PriceIncreaseStatus: &accepted,
+		}}
+	}
+	rsp := &api.DecodedStatusResponse{Data: []api.DecodedSubscriptionGroupIdentifierItem{
+		{
+			SubscriptionGroupIdentifier: "family",
+			LastTransactions: []api.DecodedLastTransactionsItem{
+				{OriginalTransactionId: "1", Status: api.SubscriptionExpired, Transaction: transaction("premium", now.Add(-time.Hour))},
+				{OriginalTransactionId: "2", Status: api.SubscriptionActive, Transaction: transaction("basic", now.Add(time.Hour)), RenewalInfo: renewal("basic", "premium")},
+				{OriginalTransactionId: "3", Status: api.SubscriptionActive, Transaction: api.DecodedTransaction{Signed: "bad", Err: errors.New("invalid")}},
+			},
+		},
+		{
+			SubscriptionGroupIdentifier: "other",
+			LastTransactions: []api.DecodedLastTransactionsItem{
+				{OriginalTransactionId: "4", Status: api.SubscriptionActive, Transaction: transaction("news", now.Add(time.Hour)), RenewalInfo: renewal("news", "news_yearly")},
+			},
+		},
+	}}
+
+	groups, err := api.ResolveSubscriptionGroups(rsp, map[string]int{"premium": 1, "basic": 2}, now)
+	if err == nil {
+		t.Error("got nil, want the error of the invalid transaction")
+	}
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+
+	family := groups[0]
+	if family.OriginalTransactionId != "2" || !family.Entitled || family.PlanChange != api.PlanChangeUpgrade || family.PriceIncrease != api.PriceIncreaseAccepted {
+		t.Errorf("unexpected family group %+v", family)
+	}
+	if other := groups[1]; other.PlanChange != api.PlanChangeUnknown || other.AutoRenewProductID != "news_yearly" {
+		t.Errorf("unexpected other group %+v", other)
+	}
+}
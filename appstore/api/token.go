@@ -1,6 +1,8 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -31,10 +33,24 @@ type Token struct {
 	IssuedAtFunc  func() int64 // The token’s creation time func. Default is current timestamp.
 	ExpiredAtFunc func() int64 // The token’s expiration time func.
 
+	// KeyProvider supplies KeyID and KeyContent on demand when set. A new key is used once the requests
+	// holding a bearer of the previous key are done, or KeyDrainTimeout after it is supplied.
+	KeyProvider      KeyProvider
+	KeyCacheDuration time.Duration   // How long the key of the KeyProvider is used before asking again. Default is DefaultKeyCacheDuration.
+	KeyDrainTimeout  time.Duration   // How long a new key waits for the requests of the previous key. Default is DefaultKeyDrainTimeout.
+	KeyErrorLog      func(err error) // Receives the KeyProvider failures and invalid keys while the current key keeps signing, when not nil.
+
 	// internal variables
 	AuthKey   *ecdsa.PrivateKey // .p8 private key
 	Bearer    string            // Authorized bearer token
 	ExpiredAt int64             // The token’s expiration time, in UNIX time
+
+	keyCheckedAt time.Time
+	keyFetch     chan struct{} // closed once the KeyProvider call in progress returns
+	pendingKey   *SigningKey   // new key waiting for the requests of the current one
+	pendingSince time.Time     // when pendingKey was supplied
+	inflight     int           // number of bearers not released yet
+	drained      chan struct{} // closed once inflight drops to zero while a key is pending
 }
 
 func (t *Token) WithConfig(c *StoreConfig) {
@@ -45,21 +61,162 @@ func (t *Token) WithConfig(c *StoreConfig) {
 	t.Sandbox = c.Sandbox
 	t.IssuedAtFunc = c.TokenIssuedAtFunc
 	t.ExpiredAtFunc = c.TokenExpiredAtFunc
+	t.KeyProvider = c.KeyProvider
+	t.KeyCacheDuration = c.KeyCacheDuration
+	t.KeyErrorLog = c.KeyErrorLog
 }
 
 // GenerateIfExpired checks to see if the token is about to expire and generates a new token.
 func (t *Token) GenerateIfExpired() (string, error) {
+	bearer, release, err := t.Acquire(context.Background())
+	if err != nil {
+		return "", err
+	}
+	release()
+	return bearer, nil
+}
+
+// Acquire returns the bearer for a request, generating a new one when it is expired or the key rotated.
+// release must be called once the request is done.
+// When the KeyProvider supplied a new key, Acquire waits until the bearers of the previous key are released,
+// KeyDrainTimeout elapses or ctx is done, in which case it returns the context error.
+func (t *Token) Acquire(ctx context.Context) (bearer string, release func(), err error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := t.refreshKey(ctx); err != nil {
+		return "", nil, err
+	}
+	for t.pendingKey != nil && t.inflight > 0 {
+		wait := t.keyDrainTimeout() - time.Since(t.pendingSince)
+		if wait <= 0 {
+			break
+		}
+		if t.drained == nil {
+			t.drained = make(chan struct{})
+		}
+		drained := t.drained
+
+		t.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-drained:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+		t.Lock()
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+	}
+	if t.pendingKey != nil {
+		t.KeyID = t.pendingKey.KeyID
+		t.KeyContent = t.pendingKey.Content
+		t.Bearer = ""
+		t.ExpiredAt = 0
+		t.pendingKey = nil
+	}
 	if t.Expired() || t.Bearer == "" {
 		err := t.Generate()
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
 	}
 
-	return t.Bearer, nil
+	t.inflight++
+	var once sync.Once
+	return t.Bearer, func() { once.Do(t.release) }, nil
+}
+
+// release releases a bearer returned by Acquire.
+func (t *Token) release() {
+	t.Lock()
+	defer t.Unlock()
+	t.inflight--
+	if t.inflight == 0 && t.drained != nil {
+		close(t.drained)
+		t.drained = nil
+	}
+}
+
+func (t *Token) keyDrainTimeout() time.Duration {
+	if t.KeyDrainTimeout <= 0 {
+		return DefaultKeyDrainTimeout
+	}
+	return t.KeyDrainTimeout
+}
+
+// refreshKey asks the KeyProvider for the key once the cached one is older than KeyCacheDuration.
+// It is called with the lock held, which it releases while the provider is called. Only one call is made at a time,
+// the other requests keep using the current key meanwhile, or wait for the call when there is none yet.
+// A new key is kept pending until Acquire can use it. The current key is kept when the provider fails
+// or supplies an invalid key, the error is passed to KeyErrorLog.
+func (t *Token) refreshKey(ctx context.Context) error {
+	if t.KeyProvider == nil {
+		return nil
+	}
+	cacheDuration := t.KeyCacheDuration
+	if cacheDuration <= 0 {
+		cacheDuration = DefaultKeyCacheDuration
+	}
+	for {
+		if !t.keyCheckedAt.IsZero() && time.Since(t.keyCheckedAt) < cacheDuration {
+			return nil
+		}
+		if t.keyFetch == nil {
+			break
+		}
+		if len(t.KeyContent) > 0 {
+			return nil
+		}
+		fetch := t.keyFetch
+		t.Unlock()
+		select {
+		case <-fetch:
+		case <-ctx.Done():
+		}
+		t.Lock()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	fetch := make(chan struct{})
+	t.keyFetch = fetch
+	t.Unlock()
+	key, err := t.KeyProvider.SigningKey(ctx)
+	if err == nil {
+		_, err = t.passKeyFromByte(key.Content)
+	}
+	t.Lock()
+	t.keyFetch = nil
+	close(fetch)
+
+	if err != nil {
+		if len(t.KeyContent) == 0 {
+			return err
+		}
+		t.keyCheckedAt = time.Now()
+		if t.KeyErrorLog != nil {
+			t.KeyErrorLog(err)
+		}
+		return nil
+	}
+	t.keyCheckedAt = time.Now()
+	if key.KeyID == t.KeyID && bytes.Equal(key.Content, t.KeyContent) {
+		t.pendingKey = nil
+		return nil
+	}
+	if t.pendingKey != nil && key.KeyID == t.pendingKey.KeyID && bytes.Equal(key.Content, t.pendingKey.Content) {
+		return nil
+	}
+
+	if t.pendingKey == nil {
+		t.pendingSince = time.Now()
+	}
+	t.pendingKey = &SigningKey{KeyID: key.KeyID, Content: append([]byte(nil), key.Content...)}
+	return nil
 }
 
 // Expired checks to see if the token has expired.
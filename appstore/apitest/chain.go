@@ -0,0 +1,98 @@
+package apitest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/awa/go-iap/appstore/chain"
+)
+
+// signingChain is a generated root, intermediate and leaf chain carrying the Apple marker OIDs.
+type signingChain struct {
+	root, intermediate, leaf *x509.Certificate
+	key                      *ecdsa.PrivateKey
+}
+
+func newSigningChain() (*signingChain, error) {
+	notBefore := time.Now().Add(-24 * time.Hour)
+	notAfter := time.Now().Add(10 * 365 * 24 * time.Hour)
+	var serial int64
+	create := func(cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool, marker asn1.ObjectIdentifier) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		serial++
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: cn, Organization: []string{"go-iap apitest"}},
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		}
+		if marker != nil {
+			tmpl.ExtraExtensions = []pkix.Extension{{Id: marker, Value: []byte{0x05, 0x00}}}
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cert, key, nil
+	}
+
+	root, rootKey, err := create("apitest Root CA", nil, nil, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, intermediateKey, err := create("apitest Intermediate CA", root, rootKey, true, chain.OIDAppleIntermediate)
+	if err != nil {
+		return nil, err
+	}
+	leaf, leafKey, err := create("apitest Signing", intermediate, intermediateKey, false, chain.OIDAppleLeaf)
+	if err != nil {
+		return nil, err
+	}
+	return &signingChain{root: root, intermediate: intermediate, leaf: leaf, key: leafKey}, nil
+}
+
+func (c *signingChain) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["x5c"] = []string{
+		base64.StdEncoding.EncodeToString(c.leaf.Raw),
+		base64.StdEncoding.EncodeToString(c.intermediate.Raw),
+		base64.StdEncoding.EncodeToString(c.root.Raw),
+	}
+	return token.SignedString(c.key)
+}
+
+// newAuthKey generates the .p8 key the StoreClient signs its requests with.
+func newAuthKey() (*ecdsa.PrivateKey, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,689 @@
+package apitest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+)
+
+// maxExtendByDays is the maximum number of days a renewal date can be extended by.
+const maxExtendByDays = 90
+
+// extendRenewalDateResponse https://developer.apple.com/documentation/appstoreserverapi/extendrenewaldateresponse
+type extendRenewalDateResponse struct {
+	OriginalTransactionId string `json:"originalTransactionId"`
+	WebOrderLineItemId    string `json:"webOrderLineItemId"`
+	Success               bool   `json:"success"`
+	EffectiveDate         int64  `json:"effectiveDate"`
+}
+
+// checkTestNotificationResponse https://developer.apple.com/documentation/appstoreserverapi/checktestnotificationresponse
+type checkTestNotificationResponse struct {
+	SignedPayload string                `json:"signedPayload"`
+	SendAttempts  []api.SendAttemptItem `json:"sendAttempts"`
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+api.PathLookUp, s.lookUpOrderID)
+	mux.HandleFunc("GET "+api.PathTransactionHistory, s.transactionHistory)
+	mux.HandleFunc("GET "+api.PathTransactionHistoryV1, s.transactionHistory)
+	mux.HandleFunc("GET "+api.PathTransactionInfo, s.transactionInfo)
+	mux.HandleFunc("GET "+api.PathRefundHistory, s.refundHistory)
+	mux.HandleFunc("GET "+api.PathGetALLSubscriptionStatus, s.subscriptionStatuses)
+	mux.HandleFunc("PUT "+api.PathExtendSubscriptionRenewalDate, s.extendRenewalDate)
+	mux.HandleFunc("POST "+api.PathExtendSubscriptionRenewalDateForAll, s.extendRenewalDateForAll)
+	mux.HandleFunc("GET "+api.PathGetStatusOfSubscriptionRenewalDate, s.renewalDateExtensionStatus)
+	mux.HandleFunc("POST "+api.PathGetNotificationHistory, s.notificationHistory)
+	mux.HandleFunc("POST "+api.PathRequestTestNotification, s.requestTestNotification)
+	mux.HandleFunc("GET "+api.PathGetTestNotificationStatus, s.testNotificationStatus)
+	// PathConsumptionInfo and PathSetAppAccountToken overlap on "/inApps/v1/transactions/consumption/appAccountToken",
+	// which ServeMux rejects, so they share one pattern.
+	mux.HandleFunc("PUT /inApps/v1/transactions/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.PathValue("id") == "consumption":
+			r.SetPathValue("originalTransactionId", r.PathValue("action"))
+			s.consumptionInfo(w, r)
+		case r.PathValue("action") == "appAccountToken":
+			r.SetPathValue("originalTransactionId", r.PathValue("id"))
+			s.setAppAccountToken(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return s.authorize(mux)
+}
+
+// authorize rejects the requests without a bearer token signed with the key of Config.
+// https://developer.apple.com/documentation/appstoreserverapi/generating_json_web_tokens_for_api_requests
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(bearer, claims, func(token *jwt.Token) (interface{}, error) {
+			return &s.authKey.PublicKey, nil
+		},
+			jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+			jwt.WithAudience("appstoreconnect-v1"),
+			jwt.WithIssuer(s.issuer),
+			jwt.WithExpirationRequired(),
+		)
+		if err != nil || claims["bid"] != s.BundleID {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the error the way the App Store Server API does, the HTTP status is the leading 3 digits of the code.
+func writeError(w http.ResponseWriter, e *api.Error) {
+	writeJSON(w, e.ErrorCode()/10000, map[string]interface{}{
+		"errorCode":    e.ErrorCode(),
+		"errorMessage": e.ErrorMessage(),
+	})
+}
+
+// paginate returns the bounds of the page starting at the revision and the revision of the next page.
+func paginate(total int, revision string, size int) (start, end int, next string, ok bool) {
+	if revision != "" {
+		var err error
+		if start, err = strconv.Atoi(revision); err != nil || start < 0 || start > total {
+			return 0, 0, "", false
+		}
+	}
+	end = start + size
+	if end > total {
+		end = total
+	}
+	return start, end, strconv.Itoa(end), true
+}
+
+// originalTransactionID resolves any transaction ID of a purchase to its original transaction ID, s.mu must be held.
+func (s *Server) originalTransactionID(transactionID string) (string, bool) {
+	if tx := s.transaction(transactionID); tx != nil {
+		return tx.OriginalTransactionId, true
+	}
+	return "", false
+}
+
+// latest returns the last purchased transaction of the original transaction, s.mu must be held.
+func (s *Server) latest(originalTransactionID string) *api.JWSTransaction {
+	var result *api.JWSTransaction
+	for _, tx := range s.history(originalTransactionID) {
+		if result == nil || tx.PurchaseDate >= result.PurchaseDate {
+			result = tx
+		}
+	}
+	return result
+}
+
+// status returns the subscription status of the transaction, s.mu must be held.
+func (s *Server) status(tx *api.JWSTransaction) api.AutoRenewSubscriptionStatus {
+	now := s.now().UnixMilli()
+	if tx.RevocationDate != 0 {
+		return api.SubscriptionRevoked
+	}
+	if tx.ExpiresDate > now {
+		return api.SubscriptionActive
+	}
+	if renewal := s.renewals[tx.OriginalTransactionId]; renewal != nil {
+		if renewal.GracePeriodExpiresDate > now {
+			return api.SubscriptionGracePeriod
+		}
+		if renewal.IsInBillingRetryPeriod != nil && *renewal.IsInBillingRetryPeriod {
+			return api.SubscriptionRetryPeriod
+		}
+	}
+	return api.SubscriptionExpired
+}
+
+// lookUpOrderID https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
+func (s *Server) lookUpOrderID(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, ok := s.orders[r.PathValue("orderId")]
+	if !ok {
+		writeJSON(w, http.StatusOK, api.OrderLookupResponse{Status: 1})
+		return
+	}
+	var txs []*api.JWSTransaction
+	for _, id := range ids {
+		if tx := s.transaction(id); tx != nil {
+			txs = append(txs, tx)
+		}
+	}
+	signed, err := s.signTransactions(txs)
+	if err != nil {
+		writeError(w, api.GeneralInternalError)
+		return
+	}
+	writeJSON(w, http.StatusOK, api.OrderLookupResponse{Status: 0, SignedTransactions: signed})
+}
+
+// transactionHistory https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
+func (s *Server) transactionHistory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("transactionId")
+	if id == "" {
+		id = r.PathValue("originalTransactionId")
+	}
+	originalTransactionID, ok := s.originalTransactionID(id)
+	if !ok {
+		writeError(w, api.TransactionIdNotFoundError)
+		return
+	}
+
+	query := r.URL.Query()
+	txs, apiErr := filterHistory(s.history(originalTransactionID), query)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	start, end, next, ok := paginate(len(txs), query.Get("revision"), s.pageSize())
+	if !ok {
+		writeError(w, api.InvalidRequestRevisionError)
+		return
+	}
+	signed, err := s.signTransactions(txs[start:end])
+	if err != nil {
+		writeError(w, api.GeneralInternalError)
+		return
+	}
+	writeJSON(w, http.StatusOK, api.HistoryResponse{
+		BundleId:           s.BundleID,
+		Environment:        s.Environment,
+		HasMore:            end < len(txs),
+		Revision:           next,
+		SignedTransactions: signed,
+	})
+}
+
+// filterHistory applies the query parameters of the transaction history.
+// https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history#query-parameters
+func filterHistory(txs []*api.JWSTransaction, query map[string][]string) ([]*api.JWSTransaction, *api.Error) {
+	productTypes := map[string]api.IAPType{
+		"AUTO_RENEWABLE": api.AutoRenewable,
+		"NON_RENEWABLE":  api.NonRenewable,
+		"CONSUMABLE":     api.Consumable,
+		"NON_CONSUMABLE": api.NonConsumable,
+	}
+	var types []string
+	for _, t := range query["productType"] {
+		iapType, ok := productTypes[t]
+		if !ok {
+			return nil, api.InvalidProductTypeError
+		}
+		types = append(types, string(iapType))
+	}
+	var startDate, endDate int64
+	var err error
+	if v := first(query["startDate"]); v != "" {
+		if startDate, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, api.InvalidStartDateError
+		}
+	}
+	if v := first(query["endDate"]); v != "" {
+		if endDate, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, api.InvalidEndDateError
+		}
+	}
+	revoked := first(query["revoked"])
+	if revoked != "" && revoked != "true" && revoked != "false" {
+		return nil, api.InvalidRevokedError
+	}
+	order := first(query["sort"])
+	if order != "" && order != "ASCENDING" && order != "DESCENDING" {
+		return nil, api.InvalidSortError
+	}
+
+	result := make([]*api.JWSTransaction, 0, len(txs))
+	for _, tx := range txs {
+		switch {
+		case len(query["productId"]) > 0 && !contains(query["productId"], tx.ProductID),
+			len(types) > 0 && !contains(types, string(tx.Type)),
+			len(query["subscriptionGroupIdentifier"]) > 0 && !contains(query["subscriptionGroupIdentifier"], tx.SubscriptionGroupIdentifier),
+			len(query["inAppOwnershipType"]) > 0 && !contains(query["inAppOwnershipType"], tx.InAppOwnershipType),
+			startDate != 0 && tx.PurchaseDate < startDate,
+			endDate != 0 && tx.PurchaseDate >= endDate,
+			revoked == "true" && tx.RevocationDate == 0,
+			revoked == "false" && tx.RevocationDate != 0:
+			continue
+		}
+		result = append(result, tx)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if order == "DESCENDING" {
+			return result[i].PurchaseDate > result[j].PurchaseDate
+		}
+		return result[i].PurchaseDate < result[j].PurchaseDate
+	})
+	return result, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// transactionInfo https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
+func (s *Server) transactionInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.transaction(r.PathValue("transactionId"))
+	if tx == nil {
+		writeError(w, api.TransactionIdNotFoundError)
+		return
+	}
+	signed, err := s.signTransaction(tx)
+	if err != nil {
+		writeError(w, api.GeneralInternalError)
+		return
+	}
+	writeJSON(w, http.StatusOK, api.TransactionInfoResponse{SignedTransactionInfo: signed})
+}
+
+// refundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
+func (s *Server) refundHistory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originalTransactionID, ok := s.originalTransactionID(r.PathValue("originalTransactionId"))
+	if !ok {
+		writeError(w, api.TransactionIdNotFoundError)
+		return
+	}
+	var refunded []*api.JWSTransaction
+	for _, tx := range s.history(originalTransactionID) {
+		if tx.RevocationDate != 0 {
+			refunded = append(refunded, tx)
+		}
+	}
+	start, end, next, ok := paginate(len(refunded), r.URL.Query().Get("revision"), s.pageSize())
+	if !ok {
+		writeError(w, api.InvalidRequestRevisionError)
+		return
+	}
+	signed, err := s.signTransactions(refunded[start:end])
+	if err != nil {
+		writeError(w, api.GeneralInternalError)
+		return
+	}
+	writeJSON(w, http.StatusOK, api.RefundLookupResponse{
+		HasMore:            end < len(refunded),
+		Revision:           next,
+		SignedTransactions: signed,
+	})
+}
+
+// subscriptionStatuses https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
+// The fake has no notion of customers, it reports the subscription of the transaction only.
+func (s *Server) subscriptionStatuses(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originalTransactionID, ok := s.originalTransactionID(r.PathValue("originalTransactionId"))
+	if !ok {
+		writeError(w, api.TransactionIdNotFoundError)
+		return
+	}
+	var statuses []api.AutoRenewSubscriptionStatus
+	for _, v := range r.URL.Query()["status"] {
+		status, err := strconv.Atoi(v)
+		if err != nil || status < int(api.SubscriptionActive) || status > int(api.SubscriptionRevoked) {
+			writeError(w, api.InvalidStatusError)
+			return
+		}
+		statuses = append(statuses, api.AutoRenewSubscriptionStatus(status))
+	}
+
+	rsp := api.StatusResponse{Environment: s.Environment, BundleId: s.BundleID, Data: []api.SubscriptionGroupIdentifierItem{}}
+	tx := s.latest(originalTransactionID)
+	if tx.Type != api.AutoRenewable {
+		writeJSON(w, http.StatusOK, rsp)
+		return
+	}
+	status := s.status(tx)
+	matched := len(statuses) == 0
+	for _, v := range statuses {
+		matched = matched || v == status
+	}
+	if !matched {
+		writeJSON(w, http.StatusOK, rsp)
+		return
+	}
+
+	item := api.LastTransactionsItem{OriginalTransactionId: originalTransactionID, Status: status}
+	var err error
+	if item.SignedTransactionInfo, err = s.signTransaction(tx); err != nil {
+		writeError(w, api.GeneralInternalError)
+		return
+	}
+	if renewal := s.renewals[originalTransactionID]; renewal != nil {
+		signedRenewal := *renewal
+		signedRenewal.SignedDate = s.now().UnixMilli()
+		if item.SignedRenewalInfo, err = s.chain.sign(signedRenewal); err != nil {
+			writeError(w, api.GeneralInternalError)
+			return
+		}
+	}
+	rsp.Data = append(rsp.Data, api.SubscriptionGroupIdentifierItem{
+		SubscriptionGroupIdentifier: tx.SubscriptionGroupIdentifier,
+		LastTransactions:            []api.LastTransactionsItem{item},
+	})
+	writeJSON(w, http.StatusOK, rsp)
+}
+
+// consumptionInfo https://developer.apple.com/documentation/appstoreserverapi/send_consumption_information
+func (s *Server) consumptionInfo(w http.ResponseWriter, r *http.Request) {
+	var body api.ConsumptionRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, api.GeneralBadRequestError)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("originalTransactionId")
+	if s.transaction(id) == nil {
+		writeError(w, api.TransactionIdNotFoundError)
+		return
+	}
+	s.consumption[id] = body
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// extendRenewalDate https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
+func (s *Server) extendRenewalDate(w http.ResponseWriter, r *http.Request) {
+	var body api.ExtendRenewalDateRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, api.GeneralBadRequestError)
+		return
+	}
+	if body.ExtendByDays <= 0 || body.ExtendByDays > maxExtendByDays {
+		writeError(w, api.InvalidExtendByDaysError)
+		return
+	}
+	if body.RequestIdentifier == "" {
+		writeError(w, api.InvalidRequestIdentifierError)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	originalTransactionID := r.PathValue("originalTransactionId")
+	tx := s.latest(originalTransactionID)
+	if tx == nil {
+		writeError(w, api.OriginalTransactionIdNotFoundError)
+		return
+	}
+	if tx.Type != api.AutoRenewable || s.status(tx) != api.SubscriptionActive {
+		writeError(w, api.SubscriptionExtensionIneligibleError)
+		return
+	}
+	s.extend(tx, body.ExtendByDays)
+	writeJSON(w, http.StatusOK, extendRenewalDateResponse{
+		OriginalTransactionId: tx.OriginalTransactionId,
+		WebOrderLineItemId:    tx.WebOrderLineItemId,
+		Success:               true,
+		EffectiveDate:         tx.ExpiresDate,
+	})
+}
+
+// extend moves the expiration and the renewal date of the subscription, s.mu must be held.
+func (s *Server) extend(tx *api.JWSTransaction, days int32) {
+	extension := (time.Duration(days) * 24 * time.Hour).Milliseconds()
+	tx.ExpiresDate += extension
+	if renewal := s.renewals[tx.OriginalTransactionId]; renewal != nil && renewal.RenewalDate != 0 {
+		renewal.RenewalDate += extension
+	}
+}
+
+// extendRenewalDateForAll https://developer.apple.com/documentation/appstoreserverapi/extend_subscription_renewal_dates_for_all_active_subscribers
+// The extension is applied immediately.
+func (s *Server) extendRenewalDateForAll(w http.ResponseWriter, r *http.Request) {
+	var body api.MassExtendRenewalDateRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, api.GeneralBadRequestError)
+		return
+	}
+	if body.ExtendByDays <= 0 || body.ExtendByDays > maxExtendByDays {
+		writeError(w, api.InvalidExtendByDaysError)
+		return
+	}
+	if body.RequestIdentifier == "" {
+		writeError(w, api.InvalidRequestIdentifierError)
+		return
+	}
+	if body.StorefrontCountryCodes != nil && len(body.StorefrontCountryCodes) == 0 {
+		writeError(w, api.InvalidEmptyStorefrontCountryCodeListError)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := &api.MassExtendRenewalDateStatusResponse{RequestIdentifier: body.RequestIdentifier, Complete: true, CompleteDate: s.now().UnixMilli()}
+	seen := map[string]bool{}
+	for _, stored := range s.transactions {
+		if seen[stored.OriginalTransactionId] {
+			continue
+		}
+		seen[stored.OriginalTransactionId] = true
+		tx := s.latest(stored.OriginalTransactionId)
+		if tx.ProductID != body.ProductId || tx.Type != api.AutoRenewable || s.status(tx) != api.SubscriptionActive {
+			continue
+		}
+		if len(body.StorefrontCountryCodes) > 0 && !contains(body.StorefrontCountryCodes, tx.Storefront) {
+			continue
+		}
+		s.extend(tx, body.ExtendByDays)
+		status.SucceededCount++
+	}
+	s.extensions[body.ProductId+"/"+body.RequestIdentifier] = status
+	writeJSON(w, http.StatusOK, map[string]string{"requestIdentifier": body.RequestIdentifier})
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// renewalDateExtensionStatus https://developer.apple.com/documentation/appstoreserverapi/get_status_of_subscription_renewal_date_extensions
+func (s *Server) renewalDateExtensionStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.extensions[r.PathValue("productId")+"/"+r.PathValue("requestIdentifier")]
+	if !ok {
+		writeError(w, api.StatusRequestNotFoundError)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// notificationHistory https://developer.apple.com/documentation/appstoreserverapi/get_notification_history
+func (s *Server) notificationHistory(w http.ResponseWriter, r *http.Request) {
+	var body api.NotificationHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, api.GeneralBadRequestError)
+		return
+	}
+	if body.StartDate <= 0 {
+		writeError(w, api.InvalidStartDateError)
+		return
+	}
+	if body.EndDate <= 0 {
+		writeError(w, api.InvalidEndDateError)
+		return
+	}
+	if body.EndDate <= body.StartDate {
+		writeError(w, api.StartDateAfterEndDateError)
+		return
+	}
+	if body.TransactionId != "" && body.NotificationType != "" {
+		writeError(w, api.MultipleFiltersSuppliedError)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var originalTransactionID string
+	if body.TransactionId != "" {
+		var ok bool
+		if originalTransactionID, ok = s.originalTransactionID(body.TransactionId); !ok {
+			writeError(w, api.TransactionIdNotFoundError)
+			return
+		}
+	}
+
+	var matched []appstore.SubscriptionNotificationV2DecodedPayload
+	for _, n := range s.notifications {
+		switch {
+		case body.OnlyFailures,
+			n.SignedDate < body.StartDate || n.SignedDate >= body.EndDate,
+			body.NotificationType != "" && n.NotificationType != body.NotificationType,
+			body.NotificationSubtype != "" && n.Subtype != body.NotificationSubtype,
+			originalTransactionID != "" && notificationOriginalTransactionID(n) != originalTransactionID:
+			continue
+		}
+		matched = append(matched, n)
+	}
+
+	start, end, next, ok := paginate(len(matched), r.URL.Query().Get("paginationToken"), s.pageSize())
+	if !ok {
+		writeError(w, api.InvalidPaginationTokenError)
+		return
+	}
+	rsp := api.NotificationHistoryResponses{HasMore: end < len(matched), NotificationHistory: []api.NotificationHistoryResponseItem{}}
+	if rsp.HasMore {
+		rsp.PaginationToken = next
+	}
+	for _, n := range matched[start:end] {
+		signed, err := s.chain.sign(n)
+		if err != nil {
+			writeError(w, api.GeneralInternalError)
+			return
+		}
+		rsp.NotificationHistory = append(rsp.NotificationHistory, api.NotificationHistoryResponseItem{
+			SignedPayload:          signed,
+			FirstSendAttemptResult: api.FirstSendAttemptResultSuccess,
+			SendAttempts:           []api.SendAttemptItem{{AttemptDate: n.SignedDate, SendAttemptResult: api.FirstSendAttemptResultSuccess}},
+		})
+	}
+	writeJSON(w, http.StatusOK, rsp)
+}
+
+// notificationOriginalTransactionID returns the original transaction ID of the signedTransactionInfo of the notification.
+// The payload was signed by the server, so it is decoded without verification.
+func notificationOriginalTransactionID(n appstore.SubscriptionNotificationV2DecodedPayload) string {
+	parts := strings.Split(string(n.Data.SignedTransactionInfo), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var tx api.JWSTransaction
+	if err := json.Unmarshal(payload, &tx); err != nil {
+		return ""
+	}
+	return tx.OriginalTransactionId
+}
+
+// requestTestNotification https://developer.apple.com/documentation/appstoreserverapi/request_a_test_notification
+func (s *Server) requestTestNotification(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	signed, err := s.chain.sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType:    appstore.NotificationTypeV2Test,
+		NotificationUUID:    uuid.NewString(),
+		NotificationVersion: "2.0",
+		SignedDate:          s.now().UnixMilli(),
+		Data: appstore.SubscriptionNotificationV2Data{
+			BundleID:    s.BundleID,
+			Environment: string(s.Environment),
+		},
+	})
+	if err != nil {
+		writeError(w, api.GeneralInternalError)
+		return
+	}
+	token := uuid.NewString()
+	s.testNotifications[token] = signed
+	writeJSON(w, http.StatusOK, api.SendTestNotificationResponse{TestNotificationToken: token})
+}
+
+// testNotificationStatus https://developer.apple.com/documentation/appstoreserverapi/get_test_notification_status
+func (s *Server) testNotificationStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	signed, ok := s.testNotifications[r.PathValue("testNotificationToken")]
+	if !ok {
+		writeError(w, api.TestNotificationNotFoundError)
+		return
+	}
+	writeJSON(w, http.StatusOK, checkTestNotificationResponse{
+		SignedPayload: signed,
+		SendAttempts:  []api.SendAttemptItem{{AttemptDate: s.now().UnixMilli(), SendAttemptResult: api.FirstSendAttemptResultSuccess}},
+	})
+}
+
+// setAppAccountToken https://developer.apple.com/documentation/appstoreserverapi/set-app-account-token
+func (s *Server) setAppAccountToken(w http.ResponseWriter, r *http.Request) {
+	var body api.UpdateAppAccountTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, api.GeneralBadRequestError)
+		return
+	}
+	if body.AppAccountToken != "" {
+		if _, err := uuid.Parse(body.AppAccountToken); err != nil {
+			writeError(w, api.InvalidAppAccountTokenError)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	txs := s.history(r.PathValue("originalTransactionId"))
+	if len(txs) == 0 {
+		writeError(w, api.OriginalTransactionIdNotFoundError)
+		return
+	}
+	for _, tx := range txs {
+		tx.AppAccountToken = body.AppAccountToken
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,261 @@
+// Package apitest runs an in-process fake of the App Store Server API for tests.
+// The fake keeps transactions, renewal infos and notifications in memory and signs its responses
+// with a generated certificate chain, which the StoreClient returned by Server.Client trusts.
+package apitest
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/chain"
+)
+
+// DefaultPageSize is the number of items the paginated endpoints return when Server.PageSize is zero.
+const DefaultPageSize = 20
+
+// Server is a fake App Store Server API. Environment, PageSize and Now must be set before the first request.
+type Server struct {
+	// URL is the base URL of the server, use it as StoreConfig.HostDebug.
+	URL string
+	// BundleID is the bundle ID of the app, requests authorized for another bundle ID are rejected.
+	BundleID string
+	// Environment is set on the signed payloads, Sandbox by default.
+	Environment api.Environment
+	// PageSize is the number of items per page of the history endpoints, DefaultPageSize when zero.
+	PageSize int
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+
+	srv        *httptest.Server
+	chain      *signingChain
+	authKey    *ecdsa.PrivateKey
+	authKeyPEM []byte
+	keyID      string
+	issuer     string
+
+	mu                sync.Mutex
+	transactions      []*api.JWSTransaction
+	renewals          map[string]*api.JWSRenewalInfoDecodedPayload
+	orders            map[string][]string
+	consumption       map[string]api.ConsumptionRequestBody
+	extensions        map[string]*api.MassExtendRenewalDateStatusResponse
+	notifications     []appstore.SubscriptionNotificationV2DecodedPayload
+	testNotifications map[string]string
+}
+
+// NewServer starts a fake App Store Server API for the app of bundleID. Call Close when done.
+func NewServer(bundleID string) (*Server, error) {
+	signing, err := newSigningChain()
+	if err != nil {
+		return nil, err
+	}
+	authKey, authKeyPEM, err := newAuthKey()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		BundleID:          bundleID,
+		Environment:       api.Sandbox,
+		chain:             signing,
+		authKey:           authKey,
+		authKeyPEM:        authKeyPEM,
+		keyID:             "APITESTKEY",
+		issuer:            uuid.NewString(),
+		renewals:          map[string]*api.JWSRenewalInfoDecodedPayload{},
+		orders:            map[string][]string{},
+		consumption:       map[string]api.ConsumptionRequestBody{},
+		extensions:        map[string]*api.MassExtendRenewalDateStatusResponse{},
+		testNotifications: map[string]string{},
+	}
+	s.srv = httptest.NewServer(s.routes())
+	s.URL = s.srv.URL
+	return s, nil
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Roots returns a pool which contains the root certificate the responses are signed with.
+func (s *Server) Roots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(s.chain.root)
+	return roots
+}
+
+// ChainVerifier returns a chain.Verifier which trusts the certificate chain of the server.
+func (s *Server) ChainVerifier() *chain.Verifier {
+	return &chain.Verifier{Roots: s.Roots()}
+}
+
+// Config returns a StoreConfig pointing at the server, with a generated API key and the chain of the server trusted.
+func (s *Server) Config() *api.StoreConfig {
+	return &api.StoreConfig{
+		KeyContent:    append([]byte(nil), s.authKeyPEM...),
+		KeyID:         s.keyID,
+		BundleID:      s.BundleID,
+		Issuer:        s.issuer,
+		Sandbox:       s.Environment == api.Sandbox,
+		ChainVerifier: s.ChainVerifier(),
+		HostDebug:     s.URL,
+	}
+}
+
+// Client returns a StoreClient configured with Config.
+func (s *Server) Client() *api.StoreClient {
+	return api.NewStoreClientWithHTTPClient(s.Config(), s.srv.Client())
+}
+
+// Sign signs claims with the certificate chain of the server, e.g. to build a signedPayload by hand.
+func (s *Server) Sign(claims jwt.Claims) (string, error) {
+	return s.chain.sign(claims)
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Server) pageSize() int {
+	if s.PageSize > 0 {
+		return s.PageSize
+	}
+	return DefaultPageSize
+}
+
+// AddTransaction stores a transaction, replacing the one with the same transactionId.
+// BundleID and Environment default to the ones of the server, OriginalTransactionId to TransactionID
+// and PurchaseDate to the current time.
+func (s *Server) AddTransaction(tx api.JWSTransaction) {
+	if tx.BundleID == "" {
+		tx.BundleID = s.BundleID
+	}
+	if tx.Environment == "" {
+		tx.Environment = s.Environment
+	}
+	if tx.OriginalTransactionId == "" {
+		tx.OriginalTransactionId = tx.TransactionID
+	}
+	if tx.PurchaseDate == 0 {
+		tx.PurchaseDate = s.now().UnixMilli()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, stored := range s.transactions {
+		if stored.TransactionID == tx.TransactionID {
+			s.transactions[i] = &tx
+			return
+		}
+	}
+	s.transactions = append(s.transactions, &tx)
+}
+
+// Transaction returns the stored transaction, including the changes made through the API.
+func (s *Server) Transaction(transactionID string) (api.JWSTransaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx := s.transaction(transactionID); tx != nil {
+		return *tx, true
+	}
+	return api.JWSTransaction{}, false
+}
+
+// SetRenewalInfo stores the renewal info of the subscription of info.OriginalTransactionId.
+func (s *Server) SetRenewalInfo(info api.JWSRenewalInfoDecodedPayload) {
+	if info.Environment == "" {
+		info.Environment = s.Environment
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renewals[info.OriginalTransactionId] = &info
+}
+
+// AddOrder links an order ID of a customer receipt to transactions for LookupOrderID.
+func (s *Server) AddOrder(orderID string, transactionIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[orderID] = append(s.orders[orderID], transactionIDs...)
+}
+
+// AddNotification stores a notification for the notification history.
+// NotificationUUID, SignedDate and the bundle ID and environment of its data default to the ones of the server.
+func (s *Server) AddNotification(notification appstore.SubscriptionNotificationV2DecodedPayload) {
+	if notification.NotificationUUID == "" {
+		notification.NotificationUUID = uuid.NewString()
+	}
+	if notification.NotificationVersion == "" {
+		notification.NotificationVersion = "2.0"
+	}
+	if notification.SignedDate == 0 {
+		notification.SignedDate = s.now().UnixMilli()
+	}
+	if notification.Data.BundleID == "" {
+		notification.Data.BundleID = s.BundleID
+	}
+	if notification.Data.Environment == "" {
+		notification.Data.Environment = string(s.Environment)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, notification)
+}
+
+// ConsumptionInfo returns the consumption information sent for the transaction.
+func (s *Server) ConsumptionInfo(originalTransactionID string) (api.ConsumptionRequestBody, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.consumption[originalTransactionID]
+	return body, ok
+}
+
+// transaction returns the stored transaction, s.mu must be held.
+func (s *Server) transaction(transactionID string) *api.JWSTransaction {
+	for _, tx := range s.transactions {
+		if tx.TransactionID == transactionID {
+			return tx
+		}
+	}
+	return nil
+}
+
+// history returns the transactions of the original transaction in purchase order, s.mu must be held.
+func (s *Server) history(originalTransactionID string) []*api.JWSTransaction {
+	var result []*api.JWSTransaction
+	for _, tx := range s.transactions {
+		if tx.OriginalTransactionId == originalTransactionID {
+			result = append(result, tx)
+		}
+	}
+	return result
+}
+
+func (s *Server) signTransaction(tx *api.JWSTransaction) (string, error) {
+	signed := *tx
+	signed.SignedDate = s.now().UnixMilli()
+	return s.chain.sign(signed)
+}
+
+func (s *Server) signTransactions(txs []*api.JWSTransaction) ([]string, error) {
+	result := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		signed, err := s.signTransaction(tx)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, signed)
+	}
+	return result, nil
+}
@@ -0,0 +1,253 @@
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestServer_Transactions(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.PageSize = 2
+	now := time.Now()
+	for i, id := range []string{"1001", "1002", "1003"} {
+		s.AddTransaction(api.JWSTransaction{
+			TransactionID:         id,
+			OriginalTransactionId: "1001",
+			ProductID:             "monthly",
+			Type:                  api.AutoRenewable,
+			PurchaseDate:          now.AddDate(0, i-2, 0).UnixMilli(),
+			ExpiresDate:           now.AddDate(0, i-1, 0).UnixMilli(),
+		})
+	}
+	s.AddOrder("MTXXXXXXXX", "1003")
+	client := s.Client()
+	ctx := context.Background()
+
+	info, err := client.GetTransactionInfo(ctx, "1002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := client.ParseSignedTransaction(info.SignedTransactionInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.TransactionID != "1002" || tx.BundleID != "com.example.app" || tx.Environment != api.Sandbox || tx.SignedDate == 0 {
+		t.Errorf("unexpected transaction %+v", tx)
+	}
+
+	history, err := client.GetTransactionHistory(ctx, "1003", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d pages, want 2", len(history))
+	}
+	var ids []string
+	for _, page := range history {
+		txs, err := client.ParseSignedTransactions(page.SignedTransactions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tx := range txs {
+			ids = append(ids, tx.TransactionID)
+		}
+	}
+	if len(ids) != 3 || ids[0] != "1001" || ids[2] != "1003" {
+		t.Errorf("got %v, want [1001 1002 1003]", ids)
+	}
+
+	query := &url.Values{}
+	query.Set("sort", "DESCENDING")
+	history, err = client.GetTransactionHistory(ctx, "1001", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs, err := client.ParseSignedTransactions(history[0].SignedTransactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs[0].TransactionID != "1003" {
+		t.Errorf("got %v, want 1003", txs[0].TransactionID)
+	}
+
+	order, err := client.LookupOrderID(ctx, "MTXXXXXXXX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != 0 || len(order.SignedTransactions) != 1 {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	_, err = client.GetTransactionInfo(ctx, "9999")
+	if !errors.Is(err, api.TransactionIdNotFoundError) {
+		t.Errorf("got %v, want %v", err, api.TransactionIdNotFoundError)
+	}
+}
+
+func TestServer_Subscriptions(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	now := time.Now()
+	s.AddTransaction(api.JWSTransaction{
+		TransactionID:               "2001",
+		ProductID:                   "monthly",
+		SubscriptionGroupIdentifier: "group",
+		Type:                        api.AutoRenewable,
+		ExpiresDate:                 now.Add(time.Hour).UnixMilli(),
+	})
+	s.SetRenewalInfo(api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "2001", AutoRenewStatus: api.AutoRenewStatusOn, RenewalDate: now.Add(time.Hour).UnixMilli()})
+	s.AddTransaction(api.JWSTransaction{TransactionID: "2002", Type: api.Consumable, RevocationDate: now.UnixMilli()})
+	client := s.Client()
+	ctx := context.Background()
+
+	statuses, err := client.GetALLSubscriptionStatuses(ctx, "2001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses.Data) != 1 || statuses.Data[0].LastTransactions[0].Status != api.SubscriptionActive {
+		t.Fatalf("unexpected statuses %+v", statuses)
+	}
+	renewal, err := client.ParseJWSEncodeString(statuses.Data[0].LastTransactions[0].SignedRenewalInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewal.(*api.JWSRenewalInfoDecodedPayload).AutoRenewStatus != api.AutoRenewStatusOn {
+		t.Errorf("unexpected renewal info %+v", renewal)
+	}
+
+	statusCode, err := client.ExtendSubscriptionRenewalDate(ctx, "2001", api.ExtendRenewalDateRequest{ExtendByDays: 3, RequestIdentifier: "req"})
+	if err != nil || statusCode != http.StatusOK {
+		t.Fatalf("got %d, %v", statusCode, err)
+	}
+	if tx, _ := s.Transaction("2001"); tx.ExpiresDate != now.Add(time.Hour+72*time.Hour).UnixMilli() {
+		t.Errorf("expiresDate is not extended: %v", tx.ExpiresDate)
+	}
+	if _, err := client.ExtendSubscriptionRenewalDate(ctx, "2001", api.ExtendRenewalDateRequest{ExtendByDays: 91, RequestIdentifier: "req"}); !errors.Is(err, api.InvalidExtendByDaysError) {
+		t.Errorf("got %v, want %v", err, api.InvalidExtendByDaysError)
+	}
+
+	if _, err := client.ExtendSubscriptionRenewalDateForAll(ctx, api.MassExtendRenewalDateRequest{RequestIdentifier: "mass", ExtendByDays: 1, ProductId: "monthly"}); err != nil {
+		t.Fatal(err)
+	}
+	_, extension, err := client.GetSubscriptionRenewalDataStatus(ctx, "monthly", "mass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extension.Complete || extension.SucceededCount != 1 {
+		t.Errorf("unexpected extension status %+v", extension)
+	}
+
+	refunds, err := client.GetRefundHistory(ctx, "2002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || len(refunds[0].SignedTransactions) != 1 {
+		t.Errorf("unexpected refunds %+v", refunds)
+	}
+
+	if statusCode, err := client.SendConsumptionInfo(ctx, "2002", api.ConsumptionRequestBody{CustomerConsented: true, PlayTime: 3}); err != nil || statusCode != http.StatusAccepted {
+		t.Fatalf("got %d, %v", statusCode, err)
+	}
+	if body, ok := s.ConsumptionInfo("2002"); !ok || body.PlayTime != 3 {
+		t.Errorf("unexpected consumption info %+v", body)
+	}
+
+	token := "7e3fb20b-4cdb-47cc-936d-99d65f608138"
+	if _, err := client.SetAppAccountToken(ctx, "2001", api.UpdateAppAccountTokenRequest{AppAccountToken: token}); err != nil {
+		t.Fatal(err)
+	}
+	if tx, _ := s.Transaction("2001"); tx.AppAccountToken != token {
+		t.Errorf("got %v, want %v", tx.AppAccountToken, token)
+	}
+}
+
+func TestServer_Notifications(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.PageSize = 1
+	signedTransaction, err := s.Sign(api.JWSTransaction{TransactionID: "3001", OriginalTransactionId: "3001", BundleID: "com.example.app", Environment: api.Sandbox})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddTransaction(api.JWSTransaction{TransactionID: "3001"})
+	s.AddNotification(appstore.SubscriptionNotificationV2DecodedPayload{NotificationType: appstore.NotificationTypeV2Subscribed})
+	s.AddNotification(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2DidRenew,
+		Data:             appstore.SubscriptionNotificationV2Data{SignedTransactionInfo: appstore.JWSTransaction(signedTransaction)},
+	})
+	client := s.Client()
+	ctx := context.Background()
+	request := api.NotificationHistoryRequest{StartDate: time.Now().Add(-time.Hour).UnixMilli(), EndDate: time.Now().Add(time.Hour).UnixMilli()}
+
+	history, err := client.GetAllNotificationHistory(ctx, request, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(history))
+	}
+	notification, err := (&appstore.Client{ChainVerifier: s.ChainVerifier()}).ParseSignedNotificationV2(history[1].SignedPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notification.NotificationType != appstore.NotificationTypeV2DidRenew || notification.TransactionInfo.TransactionId != "3001" {
+		t.Errorf("unexpected notification %+v", notification)
+	}
+
+	request.TransactionId = "3001"
+	history, err = client.GetAllNotificationHistory(ctx, request, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Errorf("got %d notifications, want 1", len(history))
+	}
+
+	statusCode, body, err := client.SendRequestTestNotification(ctx)
+	if err != nil || statusCode != http.StatusOK {
+		t.Fatalf("got %d, %v", statusCode, err)
+	}
+	var test api.SendTestNotificationResponse
+	if err := json.Unmarshal(body, &test); err != nil {
+		t.Fatal(err)
+	}
+	if statusCode, _, err = client.GetTestNotificationStatus(ctx, test.TestNotificationToken); err != nil || statusCode != http.StatusOK {
+		t.Errorf("got %d, %v", statusCode, err)
+	}
+	if _, _, err = client.GetTestNotificationStatus(ctx, "unknown"); !errors.Is(err, api.TestNotificationNotFoundError) {
+		t.Errorf("got %v, want %v", err, api.TestNotificationNotFoundError)
+	}
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	config := s.Config()
+	config.BundleID = "com.example.other"
+	statusCode, _, err := api.NewStoreClient(config).SendRequestTestNotification(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusUnauthorized {
+		t.Errorf("got %d, want %d", statusCode, http.StatusUnauthorized)
+	}
+}
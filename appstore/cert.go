@@ -1,6 +1,7 @@
 package appstore
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -8,28 +9,27 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/awa/go-iap/appstore/chain"
 )
 
-// rootPEM is generated through `openssl x509 -inform der -in AppleRootCA-G3.cer -out apple_root.pem`
-const rootPEM = `
------BEGIN CERTIFICATE-----
-MIICQzCCAcmgAwIBAgIILcX8iNLFS5UwCgYIKoZIzj0EAwMwZzEbMBkGA1UEAwwS
-QXBwbGUgUm9vdCBDQSAtIEczMSYwJAYDVQQLDB1BcHBsZSBDZXJ0aWZpY2F0aW9u
-IEF1dGhvcml0eTETMBEGA1UECgwKQXBwbGUgSW5jLjELMAkGA1UEBhMCVVMwHhcN
-MTQwNDMwMTgxOTA2WhcNMzkwNDMwMTgxOTA2WjBnMRswGQYDVQQDDBJBcHBsZSBS
-b290IENBIC0gRzMxJjAkBgNVBAsMHUFwcGxlIENlcnRpZmljYXRpb24gQXV0aG9y
-aXR5MRMwEQYDVQQKDApBcHBsZSBJbmMuMQswCQYDVQQGEwJVUzB2MBAGByqGSM49
-AgEGBSuBBAAiA2IABJjpLz1AcqTtkyJygRMc3RCV8cWjTnHcFBbZDuWmBSp3ZHtf
-TjjTuxxEtX/1H7YyYl3J6YRbTzBPEVoA/VhYDKX1DyxNB0cTddqXl5dvMVztK517
-IDvYuVTZXpmkOlEKMaNCMEAwHQYDVR0OBBYEFLuw3qFYM4iapIqZ3r6966/ayySr
-MA8GA1UdEwEB/wQFMAMBAf8wDgYDVR0PAQH/BAQDAgEGMAoGCCqGSM49BAMDA2gA
-MGUCMQCD6cHEFl4aXTQY2e3v9GwOAEZLuN+yRhHFD/3meoyhpmvOwgPUnPWTxnS4
-at+qIxUCMG1mihDK1A3UT82NQz60imOlM27jbdoXt2QfyFMm+YhidDkLF1vLUagM
-6BgD56KyKA==
------END CERTIFICATE-----
-`
+type Cert struct {
+	// Verifier verifies the certificate chains of the JWS. When nil, the Apple Root CA - G3 is trusted and revocation is not checked.
+	Verifier *chain.Verifier
+	// ReceiptRoots are the trusted roots of the app receipts signed with PKCS#7, chain.AppleIncRoots when nil.
+	// They are separate from the Roots of Verifier as receipts and JWS are signed under different Apple roots.
+	ReceiptRoots *x509.CertPool
+}
+
+var defaultVerifier = &chain.Verifier{}
 
-type Cert struct{}
+func (c *Cert) verifier() *chain.Verifier {
+	if c.Verifier != nil {
+		return c.Verifier
+	}
+	return defaultVerifier
+}
 
 // ExtractCertByIndex extracts the certificate from the token string by index.
 func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
@@ -63,35 +63,20 @@ func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
 	return certByte, nil
 }
 
-// VerifyCert verifies the certificate chain.
-func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate) error {
-	roots := x509.NewCertPool()
-	ok := roots.AppendCertsFromPEM([]byte(rootPEM))
-	if !ok {
-		return errors.New("failed to parse root certificate")
-	}
-
-	intermedia := x509.NewCertPool()
-	intermedia.AddCert(intermediaCert)
-
-	opts := x509.VerifyOptions{
-		Roots:         roots,
-		Intermediates: intermedia,
-	}
-	_, err := rootCert.Verify(opts)
-	if err != nil {
-		return err
-	}
-
-	_, err = leafCert.Verify(opts)
+// VerifyCert verifies the certificate chain, ctx is used by the revocation check.
+func (c *Cert) verifyCert(ctx context.Context, rootCert, intermediaCert, leafCert *x509.Certificate, signedDate time.Time) error {
+	at, err := c.verifier().VerificationTime(signedDate)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return c.verifier().VerifyAt(ctx, []*x509.Certificate{leafCert, intermediaCert, rootCert}, at)
 }
 
 func (c *Cert) ExtractPublicKeyFromToken(token string) (*ecdsa.PublicKey, error) {
+	return c.extractPublicKeyFromToken(context.Background(), token)
+}
+
+func (c *Cert) extractPublicKeyFromToken(ctx context.Context, token string) (*ecdsa.PublicKey, error) {
 	rootCertBytes, err := c.extractCertByIndex(token, 2)
 	if err != nil {
 		return nil, err
@@ -118,7 +103,7 @@ func (c *Cert) ExtractPublicKeyFromToken(token string) (*ecdsa.PublicKey, error)
 	if err != nil {
 		return nil, fmt.Errorf("appstore failed to parse leaf certificate")
 	}
-	if err = c.verifyCert(rootCert, intermediaCert, leafCert); err != nil {
+	if err = c.verifyCert(ctx, rootCert, intermediaCert, leafCert, chain.SignedDate(token)); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,469 @@
+// Package chain verifies the x5c certificate chains Apple uses to sign JWS,
+// such as App Store Server Notifications V2 and the App Store Server API responses.
+// It checks the chain against trusted roots, the marker OIDs Apple documents and,
+// optionally, the revocation status of the certificates with OCSP or CRL.
+package chain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// rootPEM is generated through `openssl x509 -inform der -in AppleRootCA-G3.cer -out apple_root.pem`
+const rootPEM = `
+-----BEGIN CERTIFICATE-----
+MIICQzCCAcmgAwIBAgIILcX8iNLFS5UwCgYIKoZIzj0EAwMwZzEbMBkGA1UEAwwS
+QXBwbGUgUm9vdCBDQSAtIEczMSYwJAYDVQQLDB1BcHBsZSBDZXJ0aWZpY2F0aW9u
+IEF1dGhvcml0eTETMBEGA1UECgwKQXBwbGUgSW5jLjELMAkGA1UEBhMCVVMwHhcN
+MTQwNDMwMTgxOTA2WhcNMzkwNDMwMTgxOTA2WjBnMRswGQYDVQQDDBJBcHBsZSBS
+b290IENBIC0gRzMxJjAkBgNVBAsMHUFwcGxlIENlcnRpZmljYXRpb24gQXV0aG9y
+aXR5MRMwEQYDVQQKDApBcHBsZSBJbmMuMQswCQYDVQQGEwJVUzB2MBAGByqGSM49
+AgEGBSuBBAAiA2IABJjpLz1AcqTtkyJygRMc3RCV8cWjTnHcFBbZDuWmBSp3ZHtf
+TjjTuxxEtX/1H7YyYl3J6YRbTzBPEVoA/VhYDKX1DyxNB0cTddqXl5dvMVztK517
+IDvYuVTZXpmkOlEKMaNCMEAwHQYDVR0OBBYEFLuw3qFYM4iapIqZ3r6966/ayySr
+MA8GA1UdEwEB/wQFMAMBAf8wDgYDVR0PAQH/BAQDAgEGMAoGCCqGSM49BAMDA2gA
+MGUCMQCD6cHEFl4aXTQY2e3v9GwOAEZLuN+yRhHFD/3meoyhpmvOwgPUnPWTxnS4
+at+qIxUCMG1mihDK1A3UT82NQz60imOlM27jbdoXt2QfyFMm+YhidDkLF1vLUagM
+6BgD56KyKA==
+-----END CERTIFICATE-----
+`
+
+// incRootPEM is generated through `openssl x509 -inform der -in AppleIncRootCertificate.cer -out apple_inc_root.pem`
+const incRootPEM = `
+-----BEGIN CERTIFICATE-----
+MIIEuzCCA6OgAwIBAgIBAjANBgkqhkiG9w0BAQUFADBiMQswCQYDVQQGEwJVUzET
+MBEGA1UEChMKQXBwbGUgSW5jLjEmMCQGA1UECxMdQXBwbGUgQ2VydGlmaWNhdGlv
+biBBdXRob3JpdHkxFjAUBgNVBAMTDUFwcGxlIFJvb3QgQ0EwHhcNMDYwNDI1MjE0
+MDM2WhcNMzUwMjA5MjE0MDM2WjBiMQswCQYDVQQGEwJVUzETMBEGA1UEChMKQXBw
+bGUgSW5jLjEmMCQGA1UECxMdQXBwbGUgQ2VydGlmaWNhdGlvbiBBdXRob3JpdHkx
+FjAUBgNVBAMTDUFwcGxlIFJvb3QgQ0EwggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAw
+ggEKAoIBAQDkkakJH5HbHkdQ6wXtXnmELes2oldMVeyLGYne+Uts9QerIjAC6Bg+
++FAJ039BqJj50cpmnCRrEdCju+QbKsMflZ56DKRHi1vUFjczy8QPTc4UadHJGXL1
+XQ7Vf1+b8iUDulWPTV0N8WQ1IxVLFVkds5T39pyez1C6wVhQZ48ItCD3y6wsIG9w
+tj8BMIy3Q88PnT3zK0koGsj+zrW5DtleHNbLPbU6rfQPDgCSC7EhFi501TwN22IW
+q6NxkkdTVcGvL0Gz+PvjcM3mo0xFfh9Ma1CWQYnEdGILEINBhzOKgbEwWOxaBDKM
+aLOPHd5lc/9nXmW8Sdh2nzMUZaF3lMktAgMBAAGjggF6MIIBdjAOBgNVHQ8BAf8E
+BAMCAQYwDwYDVR0TAQH/BAUwAwEB/zAdBgNVHQ4EFgQUK9BpR5R2Cf70a40uQKb3
+R01/CF4wHwYDVR0jBBgwFoAUK9BpR5R2Cf70a40uQKb3R01/CF4wggERBgNVHSAE
+ggEIMIIBBDCCAQAGCSqGSIb3Y2QFATCB8jAqBggrBgEFBQcCARYeaHR0cHM6Ly93
+d3cuYXBwbGUuY29tL2FwcGxlY2EvMIHDBggrBgEFBQcCAjCBthqBs1JlbGlhbmNl
+IG9uIHRoaXMgY2VydGlmaWNhdGUgYnkgYW55IHBhcnR5IGFzc3VtZXMgYWNjZXB0
+YW5jZSBvZiB0aGUgdGhlbiBhcHBsaWNhYmxlIHN0YW5kYXJkIHRlcm1zIGFuZCBj
+b25kaXRpb25zIG9mIHVzZSwgY2VydGlmaWNhdGUgcG9saWN5IGFuZCBjZXJ0aWZp
+Y2F0aW9uIHByYWN0aWNlIHN0YXRlbWVudHMuMA0GCSqGSIb3DQEBBQUAA4IBAQBc
+NplMLXi37Yyb3PN3m/J20ncwT8EfhYOFG5k9RzfyqZtAjizUsZAS2L70c5vu0mQP
+y3lPNNiiPvl4/2vIB+x9OYOLUyDTOMSxv5pPCmv/K/xZpwUJfBdAVhEedNO3iyM7
+R6PVbyTi69G3cN8PReEnyvFteO3ntRcXqNx+IjXKJdXZD9Zr1KIkIxH3oayPc4Fg
+xhtbCS+SsvhESPBgOJ4V9T0mZyCKM2r3DYLP3uujL/lTaltkwGMzd/c6ByxW69oP
+IQ7aunMZT7XZNn/Bh1XZp5m5MkL72NVxnn6hUrcbvZNCJBIqxw8dtk2cXmPIS4AX
+UKqK1drk/NAJBzewdXUh
+-----END CERTIFICATE-----
+`
+
+// Marker OIDs Apple sets on the certificates of the chain.
+// https://developer.apple.com/documentation/appstoreserverapi/jwstransaction
+var (
+	OIDAppleLeaf         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	OIDAppleIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+// list of errors
+var (
+	ErrInvalidChainLength = errors.New("chain: x5c must contain the leaf, intermediate and root certificates")
+	ErrMissingAppleOID    = errors.New("chain: certificate does not have the Apple marker OID")
+	ErrRevoked            = errors.New("chain: certificate has been revoked")
+	ErrRevocationUnknown  = errors.New("chain: revocation status of the certificate is unknown")
+	ErrSignedInFuture     = errors.New("chain: signedDate of the payload is in the future")
+)
+
+// RevocationMode selects how the revocation of the leaf and intermediate certificates is checked.
+type RevocationMode int
+
+// list of RevocationMode
+const (
+	// RevocationNone does not check the revocation status.
+	RevocationNone RevocationMode = iota
+	// RevocationOCSP asks the OCSP responder of each certificate.
+	RevocationOCSP
+	// RevocationCRL downloads the CRL of each certificate.
+	RevocationCRL
+	// RevocationOCSPOrCRL asks the OCSP responder and falls back to the CRL when the certificate has no responder or it fails.
+	RevocationOCSPOrCRL
+)
+
+// DefaultFutureSkew is the tolerance for payloads signed in the future when Verifier.FutureSkew is zero.
+const DefaultFutureSkew = 5 * time.Minute
+
+// DefaultCacheTTL is how long a revocation status is cached when the response does not tell its next update.
+const DefaultCacheTTL = time.Hour
+
+// Fetcher sends the OCSP and CRL requests, *http.Client implements it.
+type Fetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Cache stores the revocation status of certificates, keyed by the SHA-256 fingerprint of the certificate.
+type Cache interface {
+	Get(key string) (revoked bool, ok bool)
+	Set(key string, revoked bool, expiresAt time.Time)
+}
+
+// Verifier verifies x5c certificate chains. The zero value trusts the Apple Root CA - G3 and does not check revocation.
+// A Verifier is safe for concurrent use.
+type Verifier struct {
+	// Roots are the trusted root certificates, AppleRoots when nil.
+	Roots *x509.CertPool
+	// Revocation enables the revocation check of the leaf and intermediate certificates.
+	// A certificate whose status cannot be fetched fails the verification.
+	Revocation RevocationMode
+	// Fetcher sends the revocation requests, an http.Client with a 10 seconds timeout when nil.
+	Fetcher Fetcher
+	// Cache stores the revocation status, a MemoryCache using the clock of the verifier when nil.
+	Cache Cache
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+	// VerifyAtSignedDate verifies the chain as of the signedDate of the payload instead of the current time,
+	// so payloads signed before a certificate expired, such as the notification history, stay valid.
+	VerifyAtSignedDate bool
+	// FutureSkew is how far in the future signedDate may be when VerifyAtSignedDate is set, DefaultFutureSkew when zero.
+	FutureSkew time.Duration
+
+	once    sync.Once
+	fetcher Fetcher
+	cache   Cache
+}
+
+// AppleRoots returns a pool which contains the Apple Root CA - G3.
+// https://www.apple.com/certificateauthority/
+func AppleRoots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(rootPEM))
+	return roots
+}
+
+// AppleIncRoots returns a pool which contains the RSA Apple Inc. Root, which signs the app receipts.
+// https://www.apple.com/certificateauthority/
+func AppleIncRoots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(incRootPEM))
+	return roots
+}
+
+// RootPool returns the trusted root certificates.
+func (v *Verifier) RootPool() *x509.CertPool {
+	if v.Roots != nil {
+		return v.Roots
+	}
+	return AppleRoots()
+}
+
+// CurrentTime returns the time of the clock of the verifier.
+func (v *Verifier) CurrentTime() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// VerificationTime returns the time the chain of a payload signed at signedDate must be valid at.
+// It is the current time unless VerifyAtSignedDate is set and signedDate is not zero.
+// ErrSignedInFuture is returned when signedDate is later than the current time plus FutureSkew.
+func (v *Verifier) VerificationTime(signedDate time.Time) (time.Time, error) {
+	now := v.CurrentTime()
+	if !v.VerifyAtSignedDate || signedDate.IsZero() {
+		return now, nil
+	}
+	skew := v.FutureSkew
+	if skew == 0 {
+		skew = DefaultFutureSkew
+	}
+	if signedDate.After(now.Add(skew)) {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrSignedInFuture, signedDate.UTC().Format(time.RFC3339))
+	}
+	return signedDate, nil
+}
+
+// SignedDate returns the signedDate of the payload of a JWS, or the zero time when the payload does not have it.
+// The payload is not verified, the signature verification with the leaf certificate covers it afterwards.
+func SignedDate(jws string) time.Time {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payloadByte, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var payload struct {
+		SignedDate int64 `json:"signedDate"`
+	}
+	if err := json.Unmarshal(payloadByte, &payload); err != nil || payload.SignedDate == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(payload.SignedDate)
+}
+
+func (v *Verifier) init() {
+	v.once.Do(func() {
+		v.fetcher = v.Fetcher
+		if v.fetcher == nil {
+			v.fetcher = &http.Client{Timeout: 10 * time.Second}
+		}
+		v.cache = v.Cache
+		if v.cache == nil {
+			cache := NewMemoryCache()
+			cache.Now = v.CurrentTime
+			v.cache = cache
+		}
+	})
+}
+
+// VerifyX5C decodes the base64 encoded certificates of a x5c header, verifies them as of the current time and returns the leaf certificate.
+func (v *Verifier) VerifyX5C(ctx context.Context, x5c []string) (*x509.Certificate, error) {
+	return v.VerifyX5CAt(ctx, x5c, v.CurrentTime())
+}
+
+// VerifyX5CAt verifies the x5c header like VerifyX5C, as of at.
+func (v *Verifier) VerifyX5CAt(ctx context.Context, x5c []string, at time.Time) (*x509.Certificate, error) {
+	if len(x5c) != 3 {
+		return nil, ErrInvalidChainLength
+	}
+	certs := make([]*x509.Certificate, 0, len(x5c))
+	for _, s := range x5c {
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("chain: failed to decode certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("chain: failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if err := v.VerifyAt(ctx, certs, at); err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// Verify verifies the certificates in the order of a x5c header: leaf, intermediate and root, as of the current time.
+func (v *Verifier) Verify(ctx context.Context, certs []*x509.Certificate) error {
+	return v.VerifyAt(ctx, certs, v.CurrentTime())
+}
+
+// VerifyAt verifies the certificates like Verify, as of at.
+func (v *Verifier) VerifyAt(ctx context.Context, certs []*x509.Certificate, at time.Time) error {
+	if len(certs) != 3 {
+		return ErrInvalidChainLength
+	}
+	leaf, intermediate, root := certs[0], certs[1], certs[2]
+
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(intermediate)
+	opts := x509.VerifyOptions{
+		Roots:         v.RootPool(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		CurrentTime:   at,
+	}
+	if _, err := root.Verify(opts); err != nil {
+		return err
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		return err
+	}
+
+	if !hasExtension(leaf, OIDAppleLeaf) {
+		return fmt.Errorf("%w: leaf %s", ErrMissingAppleOID, OIDAppleLeaf)
+	}
+	if !hasExtension(intermediate, OIDAppleIntermediate) {
+		return fmt.Errorf("%w: intermediate %s", ErrMissingAppleOID, OIDAppleIntermediate)
+	}
+
+	if v.Revocation == RevocationNone {
+		return nil
+	}
+	if err := v.checkRevocation(ctx, leaf, intermediate); err != nil {
+		return err
+	}
+	return v.checkRevocation(ctx, intermediate, root)
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) checkRevocation(ctx context.Context, cert, issuer *x509.Certificate) error {
+	v.init()
+
+	sum := sha256.Sum256(cert.Raw)
+	key := hex.EncodeToString(sum[:])
+	revoked, ok := v.cache.Get(key)
+	if !ok {
+		var (
+			nextUpdate time.Time
+			err        error
+		)
+		switch v.Revocation {
+		case RevocationOCSP:
+			revoked, nextUpdate, err = v.ocspStatus(ctx, cert, issuer)
+		case RevocationCRL:
+			revoked, nextUpdate, err = v.crlStatus(ctx, cert, issuer)
+		default:
+			revoked, nextUpdate, err = v.ocspStatus(ctx, cert, issuer)
+			if err != nil {
+				revoked, nextUpdate, err = v.crlStatus(ctx, cert, issuer)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if nextUpdate.IsZero() {
+			nextUpdate = v.CurrentTime().Add(DefaultCacheTTL)
+		}
+		v.cache.Set(key, revoked, nextUpdate)
+	}
+
+	if revoked {
+		return fmt.Errorf("%w: %s", ErrRevoked, cert.Subject)
+	}
+	return nil
+}
+
+func (v *Verifier) ocspStatus(ctx context.Context, cert, issuer *x509.Certificate) (bool, time.Time, error) {
+	if len(cert.OCSPServer) == 0 {
+		return false, time.Time{}, fmt.Errorf("%w: %s has no OCSP responder", ErrRevocationUnknown, cert.Subject)
+	}
+	reqBody, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(reqBody))
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	body, err := v.fetch(req)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	resp, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("%w: %v", ErrRevocationUnknown, err)
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return false, resp.NextUpdate, nil
+	case ocsp.Revoked:
+		return true, resp.NextUpdate, nil
+	default:
+		return false, time.Time{}, fmt.Errorf("%w: OCSP status of %s is unknown", ErrRevocationUnknown, cert.Subject)
+	}
+}
+
+func (v *Verifier) crlStatus(ctx context.Context, cert, issuer *x509.Certificate) (bool, time.Time, error) {
+	if len(cert.CRLDistributionPoints) == 0 {
+		return false, time.Time{}, fmt.Errorf("%w: %s has no CRL distribution point", ErrRevocationUnknown, cert.Subject)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cert.CRLDistributionPoints[0], nil)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	body, err := v.fetch(req)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("%w: %v", ErrRevocationUnknown, err)
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return false, time.Time{}, fmt.Errorf("%w: %v", ErrRevocationUnknown, err)
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true, crl.NextUpdate, nil
+		}
+	}
+	return false, crl.NextUpdate, nil
+}
+
+func (v *Verifier) fetch(req *http.Request) ([]byte, error) {
+	resp, err := v.fetcher.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRevocationUnknown, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s responded %d", ErrRevocationUnknown, req.URL, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+}
+
+// MemoryCache is an in-memory Cache.
+type MemoryCache struct {
+	// Now returns the current time to expire the entries, time.Now when nil.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryCacheEntry)}
+}
+
+// Get returns the cached status, ok is false when it is missing or expired.
+func (c *MemoryCache) Get(key string) (revoked bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return false, false
+	}
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+	if now.After(entry.expiresAt) {
+		delete(c.entries, key)
+		return false, false
+	}
+	return entry.revoked, true
+}
+
+// Set caches the status until expiresAt.
+func (c *MemoryCache) Set(key string, revoked bool, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = memoryCacheEntry{revoked: revoked, expiresAt: expiresAt}
+}
@@ -0,0 +1,399 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool, marker asn1.ObjectIdentifier) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		OCSPServer:            []string{"http://ocsp.example.com/" + cn},
+		CRLDistributionPoints: []string{"http://crl.example.com/" + cn},
+	}
+	if marker != nil {
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: marker, Value: []byte{0x05, 0x00}}}
+	}
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+type testChain struct {
+	root, intermediate, leaf *testCert
+}
+
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+	root := newTestCert(t, "root", nil, true, nil)
+	intermediate := newTestCert(t, "intermediate", root, true, OIDAppleIntermediate)
+	leaf := newTestCert(t, "leaf", intermediate, false, OIDAppleLeaf)
+	return &testChain{root: root, intermediate: intermediate, leaf: leaf}
+}
+
+func (c *testChain) certs() []*x509.Certificate {
+	return []*x509.Certificate{c.leaf.cert, c.intermediate.cert, c.root.cert}
+}
+
+func (c *testChain) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.root.cert)
+	return pool
+}
+
+type fetcherFunc func(req *http.Request) (*http.Response, error)
+
+func (f fetcherFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func respond(body []byte) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}
+}
+
+// revocationFetcher answers OCSP and CRL requests for the chain, revoked certificates are reported as revoked.
+func revocationFetcher(t *testing.T, c *testChain, calls *int32, revoked ...*x509.Certificate) Fetcher {
+	isRevoked := func(cert *x509.Certificate) bool {
+		for _, r := range revoked {
+			if r.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	issuers := map[string][2]*testCert{
+		"leaf":         {c.leaf, c.intermediate},
+		"intermediate": {c.intermediate, c.root},
+	}
+
+	return fetcherFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(calls, 1)
+		pair, ok := issuers[req.URL.Path[1:]]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+		}
+		cert, issuer := pair[0].cert, pair[1]
+
+		switch req.URL.Host {
+		case "ocsp.example.com":
+			status := ocsp.Good
+			if isRevoked(cert) {
+				status = ocsp.Revoked
+			}
+			body, err := ocsp.CreateResponse(issuer.cert, issuer.cert, ocsp.Response{
+				Status:       status,
+				SerialNumber: cert.SerialNumber,
+				ThisUpdate:   time.Now().Add(-time.Minute),
+				NextUpdate:   time.Now().Add(time.Hour),
+				RevokedAt:    time.Now().Add(-time.Minute),
+			}, issuer.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return respond(body), nil
+		default:
+			list := &x509.RevocationList{
+				Number:     big.NewInt(1),
+				ThisUpdate: time.Now().Add(-time.Minute),
+				NextUpdate: time.Now().Add(time.Hour),
+			}
+			if isRevoked(cert) {
+				list.RevokedCertificateEntries = []x509.RevocationListEntry{{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()}}
+			}
+			body, err := x509.CreateRevocationList(rand.Reader, list, issuer.cert, issuer.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return respond(body), nil
+		}
+	})
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	t.Parallel()
+	c := newTestChain(t)
+
+	t.Run("valid", func(t *testing.T) {
+		v := &Verifier{Roots: c.roots()}
+		if err := v.Verify(context.Background(), c.certs()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("untrusted root", func(t *testing.T) {
+		v := &Verifier{}
+		var unknownAuthority x509.UnknownAuthorityError
+		if err := v.Verify(context.Background(), c.certs()); !errors.As(err, &unknownAuthority) {
+			t.Errorf("got %v, want x509.UnknownAuthorityError", err)
+		}
+	})
+
+	t.Run("invalid length", func(t *testing.T) {
+		v := &Verifier{Roots: c.roots()}
+		if err := v.Verify(context.Background(), c.certs()[:2]); !errors.Is(err, ErrInvalidChainLength) {
+			t.Errorf("got %v, want %v", err, ErrInvalidChainLength)
+		}
+	})
+
+	t.Run("missing marker OID", func(t *testing.T) {
+		leaf := newTestCert(t, "leaf", c.intermediate, false, nil)
+		v := &Verifier{Roots: c.roots()}
+		err := v.Verify(context.Background(), []*x509.Certificate{leaf.cert, c.intermediate.cert, c.root.cert})
+		if !errors.Is(err, ErrMissingAppleOID) {
+			t.Errorf("got %v, want %v", err, ErrMissingAppleOID)
+		}
+	})
+}
+
+func TestVerifier_Clock(t *testing.T) {
+	t.Parallel()
+	c := newTestChain(t)
+	later := time.Now().Add(2 * time.Hour)
+	v := &Verifier{Roots: c.roots(), Now: func() time.Time { return later }}
+
+	var invalid x509.CertificateInvalidError
+	if err := v.Verify(context.Background(), c.certs()); !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+		t.Errorf("got %v, want an expired certificate error", err)
+	}
+	if err := v.VerifyAt(context.Background(), c.certs(), time.Now()); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
+
+func TestVerifier_VerificationTime(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	tests := []struct {
+		name       string
+		verifier   *Verifier
+		signedDate time.Time
+		want       time.Time
+		wantErr    error
+	}{
+		{name: "current time", verifier: &Verifier{Now: clock}, signedDate: now.Add(-24 * time.Hour), want: now},
+		{name: "signed date", verifier: &Verifier{Now: clock, VerifyAtSignedDate: true}, signedDate: now.Add(-24 * time.Hour), want: now.Add(-24 * time.Hour)},
+		{name: "no signed date", verifier: &Verifier{Now: clock, VerifyAtSignedDate: true}, want: now},
+		{name: "within default skew", verifier: &Verifier{Now: clock, VerifyAtSignedDate: true}, signedDate: now.Add(time.Minute), want: now.Add(time.Minute)},
+		{name: "beyond default skew", verifier: &Verifier{Now: clock, VerifyAtSignedDate: true}, signedDate: now.Add(10 * time.Minute), wantErr: ErrSignedInFuture},
+		{name: "within custom skew", verifier: &Verifier{Now: clock, VerifyAtSignedDate: true, FutureSkew: time.Hour}, signedDate: now.Add(10 * time.Minute), want: now.Add(10 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.verifier.VerificationTime(tt.signedDate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyX5C(t *testing.T) {
+	t.Parallel()
+	c := newTestChain(t)
+	var x5c []string
+	for _, cert := range c.certs() {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	v := &Verifier{Roots: c.roots()}
+	leaf, err := v.VerifyX5C(context.Background(), x5c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !leaf.Equal(c.leaf.cert) {
+		t.Error("expected the leaf certificate")
+	}
+
+	if _, err := v.VerifyX5C(context.Background(), []string{"!", x5c[1], x5c[2]}); err == nil {
+		t.Error("expected an error for a malformed certificate")
+	}
+}
+
+func TestVerifier_Revocation(t *testing.T) {
+	t.Parallel()
+	c := newTestChain(t)
+
+	for _, mode := range []RevocationMode{RevocationOCSP, RevocationCRL, RevocationOCSPOrCRL} {
+		var calls int32
+		v := &Verifier{Roots: c.roots(), Revocation: mode, Fetcher: revocationFetcher(t, c, &calls)}
+		if err := v.Verify(context.Background(), c.certs()); err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if err := v.Verify(context.Background(), c.certs()); err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if calls != 2 {
+			t.Errorf("mode %d: got %d requests, want 2 since the status is cached", mode, calls)
+		}
+
+		calls = 0
+		v = &Verifier{Roots: c.roots(), Revocation: mode, Fetcher: revocationFetcher(t, c, &calls, c.leaf.cert)}
+		if err := v.Verify(context.Background(), c.certs()); !errors.Is(err, ErrRevoked) {
+			t.Errorf("mode %d: got %v, want %v", mode, err, ErrRevoked)
+		}
+
+		v = &Verifier{Roots: c.roots(), Revocation: mode, Fetcher: revocationFetcher(t, c, &calls, c.intermediate.cert)}
+		if err := v.Verify(context.Background(), c.certs()); !errors.Is(err, ErrRevoked) {
+			t.Errorf("mode %d: got %v, want %v", mode, err, ErrRevoked)
+		}
+	}
+
+	t.Run("fetch failure", func(t *testing.T) {
+		v := &Verifier{Roots: c.roots(), Revocation: RevocationOCSPOrCRL, Fetcher: fetcherFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})}
+		if err := v.Verify(context.Background(), c.certs()); !errors.Is(err, ErrRevocationUnknown) {
+			t.Errorf("got %v, want %v", err, ErrRevocationUnknown)
+		}
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+	t.Parallel()
+	cache := NewMemoryCache()
+	cache.Set("good", false, time.Now().Add(time.Hour))
+	cache.Set("revoked", true, time.Now().Add(time.Hour))
+	cache.Set("expired", true, time.Now().Add(-time.Second))
+
+	if revoked, ok := cache.Get("good"); !ok || revoked {
+		t.Errorf("good: got %v, %v", revoked, ok)
+	}
+	if revoked, ok := cache.Get("revoked"); !ok || !revoked {
+		t.Errorf("revoked: got %v, %v", revoked, ok)
+	}
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("expired entry should be missing")
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("missing entry should be missing")
+	}
+}
+
+func TestVerifier_RevocationClock(t *testing.T) {
+	t.Parallel()
+	c := newTestChain(t)
+	var calls int32
+	now := time.Now()
+	v := &Verifier{Roots: c.roots(), Revocation: RevocationOCSP, Fetcher: revocationFetcher(t, c, &calls), Now: func() time.Time { return now }}
+	if err := v.Verify(context.Background(), c.certs()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cached statuses expire with the clock of the verifier
+	now = now.Add(2 * time.Hour)
+	if err := v.VerifyAt(context.Background(), c.certs(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Errorf("got %d requests, want 4 since the cached statuses expired", calls)
+	}
+}
+
+func TestVerifier_RevocationContext(t *testing.T) {
+	t.Parallel()
+	c := newTestChain(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	v := &Verifier{Roots: c.roots(), Revocation: RevocationOCSP, Fetcher: fetcherFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("the request does not carry the context")
+	})}
+	if err := v.Verify(ctx, c.certs()); !errors.Is(err, ErrRevocationUnknown) || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("got %v, want %v caused by %v", err, ErrRevocationUnknown, context.Canceled)
+	}
+}
+
+func TestAppleRoots(t *testing.T) {
+	t.Parallel()
+	if AppleRoots().Equal(x509.NewCertPool()) {
+		t.Error("expected the Apple root certificate")
+	}
+}
+
+func TestAppleIncRoots(t *testing.T) {
+	t.Parallel()
+	block, _ := pem.Decode([]byte(incRootPEM))
+	root, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Subject.CommonName != "Apple Root CA" || root.PublicKeyAlgorithm != x509.RSA {
+		t.Errorf("unexpected root %v", root.Subject)
+	}
+	if _, err := root.Verify(x509.VerifyOptions{Roots: AppleIncRoots(), CurrentTime: root.NotBefore}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignedDate(t *testing.T) {
+	t.Parallel()
+	encode := func(payload string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2ln"
+	}
+	tests := []struct {
+		name string
+		jws  string
+		want time.Time
+	}{
+		{name: "signed date", jws: encode(`{"signedDate":1700000000123}`), want: time.UnixMilli(1700000000123)},
+		{name: "no signed date", jws: encode(`{"transactionId":"1"}`)},
+		{name: "malformed payload", jws: encode(`{`)},
+		{name: "malformed jws", jws: "e30"},
+	}
+	for _, tt := range tests {
+		if got := SignedDate(tt.jws); !got.Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package appstore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+// Entitlement converts the in-app purchase into a normalized entitlement as of now.
+// The receipt does not contain the price, so PriceMicros and Currency are left empty.
+func (i InApp) Entitlement(now time.Time) entitlement.Entitlement {
+	e := entitlement.Entitlement{
+		Store:         entitlement.AppStore,
+		ProductID:     i.ProductID,
+		OriginalID:    string(i.OriginalTransactionID),
+		TransactionID: i.TransactionID,
+		PeriodStart:   msStringToTime(i.PurchaseDateMS),
+		PeriodEnd:     msStringToTime(i.ExpiresDateMS),
+		Trial:         i.IsTrialPeriod == "true",
+		IntroOffer:    i.IsInIntroOfferPeriod == "true",
+	}
+	if e.OriginalID == "" {
+		e.OriginalID = i.TransactionID
+	}
+	e.State = entitlement.ExpiredAt(e.PeriodEnd, now)
+	if i.CancellationDateMS != "" {
+		e.Revoked = true
+		e.RevokedAt = msStringToTime(i.CancellationDateMS)
+		e.State = entitlement.StateRevoked
+	}
+	return e
+}
+
+// Entitlements converts the response into normalized entitlements as of now.
+// The latest transaction of each subscription is used, the renewal state is taken from pending_renewal_info.
+func (r *IAPResponse) Entitlements(now time.Time) []entitlement.Entitlement {
+	txs := r.LatestReceiptInfo
+	if len(txs) == 0 {
+		txs = r.Receipt.InApp
+	}
+
+	var keys []string
+	latest := make(map[string]InApp, len(txs))
+	for _, tx := range txs {
+		key := tx.TransactionID
+		if tx.ExpiresDateMS != "" && tx.OriginalTransactionID != "" {
+			key = string(tx.OriginalTransactionID)
+		}
+		prev, ok := latest[key]
+		if !ok {
+			keys = append(keys, key)
+		}
+		if !ok || msStringToInt(tx.PurchaseDateMS) > msStringToInt(prev.PurchaseDateMS) {
+			latest[key] = tx
+		}
+	}
+
+	renewals := make(map[string]PendingRenewalInfo, len(r.PendingRenewalInfo))
+	for _, info := range r.PendingRenewalInfo {
+		renewals[info.OriginalTransactionID] = info
+	}
+
+	entitlements := make([]entitlement.Entitlement, 0, len(keys))
+	for _, key := range keys {
+		e := latest[key].Entitlement(now)
+		if info, ok := renewals[e.OriginalID]; ok {
+			e.AutoRenew = info.SubscriptionAutoRenewStatus == "1"
+			if e.State == entitlement.StateExpired {
+				if grace := msStringToTime(info.GracePeriodDateMS); grace.After(now) {
+					e.State = entitlement.StateGracePeriod
+				} else if info.SubscriptionRetryFlag == "1" {
+					e.State = entitlement.StateBillingRetry
+				}
+			}
+		}
+		entitlements = append(entitlements, e)
+	}
+	return entitlements
+}
+
+func msStringToInt(ms string) int64 {
+	v, _ := strconv.ParseInt(ms, 10, 64)
+	return v
+}
+
+func msStringToTime(ms string) time.Time {
+	return entitlement.FromMillis(msStringToInt(ms))
+}
+
+// EntitlementVerifier verifies App Store receipts as an entitlement.Verifier.
+type EntitlementVerifier struct {
+	client   IAPClient
+	password string
+}
+
+// Verify that EntitlementVerifier implements entitlement.Verifier
+var _ entitlement.Verifier = (*EntitlementVerifier)(nil)
+
+// NewEntitlementVerifier creates a verifier which sends the receipts with the shared secret password.
+func NewEntitlementVerifier(client IAPClient, password string) *EntitlementVerifier {
+	return &EntitlementVerifier{client: client, password: password}
+}
+
+// Verify sends the receipt data in purchase.Token to the App Store, the result contains *IAPResponse as Raw.
+func (v *EntitlementVerifier) Verify(ctx context.Context, purchase entitlement.Purchase) (*entitlement.Result, error) {
+	resp := &IAPResponse{}
+	req := IAPRequest{ReceiptData: purchase.Token, Password: v.password}
+	if err := v.client.Verify(ctx, req, resp); err != nil {
+		return nil, err
+	}
+	if err := HandleError(resp.Status); err != nil {
+		return nil, err
+	}
+	return &entitlement.Result{Entitlements: resp.Entitlements(time.Now()), Raw: resp}, nil
+}
@@ -0,0 +1,107 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+func TestIAPResponse_Entitlements(t *testing.T) {
+	t.Parallel()
+	now := time.UnixMilli(1600000000000)
+	resp := &IAPResponse{
+		LatestReceiptInfo: []InApp{
+			{
+				ProductID:             "monthly",
+				TransactionID:         "2",
+				OriginalTransactionID: "1",
+				PurchaseDate:          PurchaseDate{PurchaseDateMS: "1599000000000"},
+				ExpiresDate:           ExpiresDate{ExpiresDateMS: "1599900000000"},
+			},
+			{
+				ProductID:             "monthly",
+				TransactionID:         "1",
+				OriginalTransactionID: "1",
+				IsTrialPeriod:         "true",
+				PurchaseDate:          PurchaseDate{PurchaseDateMS: "1598000000000"},
+				ExpiresDate:           ExpiresDate{ExpiresDateMS: "1599000000000"},
+			},
+			{
+				ProductID:             "lifetime",
+				TransactionID:         "3",
+				OriginalTransactionID: "3",
+				PurchaseDate:          PurchaseDate{PurchaseDateMS: "1590000000000"},
+				CancellationDate:      CancellationDate{CancellationDateMS: "1591000000000"},
+			},
+		},
+		PendingRenewalInfo: []PendingRenewalInfo{
+			{
+				OriginalTransactionID:       "1",
+				SubscriptionAutoRenewStatus: "1",
+				GracePeriodDate:             GracePeriodDate{GracePeriodDateMS: "1600100000000"},
+			},
+		},
+	}
+
+	got := resp.Entitlements(now)
+	if len(got) != 2 {
+		t.Fatalf("got %d entitlements, want 2", len(got))
+	}
+
+	expected := entitlement.Entitlement{
+		Store:         entitlement.AppStore,
+		ProductID:     "monthly",
+		OriginalID:    "1",
+		TransactionID: "2",
+		State:         entitlement.StateGracePeriod,
+		PeriodStart:   time.UnixMilli(1599000000000),
+		PeriodEnd:     time.UnixMilli(1599900000000),
+		AutoRenew:     true,
+	}
+	if got[0] != expected {
+		t.Errorf("got %+v\nwant %+v", got[0], expected)
+	}
+	if !got[0].HasAccess() {
+		t.Error("expected access during the grace period")
+	}
+
+	if got[1].State != entitlement.StateRevoked || !got[1].Revoked || !got[1].RevokedAt.Equal(time.UnixMilli(1591000000000)) {
+		t.Errorf("got %+v, want revoked", got[1])
+	}
+}
+
+type fakeIAPClient struct {
+	IAPClient
+	status int
+}
+
+func (f fakeIAPClient) Verify(ctx context.Context, reqBody IAPRequest, resp interface{}) error {
+	_, err := f.VerifyWithStatus(ctx, reqBody, resp)
+	return err
+}
+
+func (f fakeIAPClient) VerifyWithStatus(ctx context.Context, reqBody IAPRequest, resp interface{}) (int, error) {
+	r := resp.(*IAPResponse)
+	r.Status = f.status
+	r.Receipt.InApp = []InApp{{ProductID: "lifetime", TransactionID: reqBody.ReceiptData}}
+	return 200, nil
+}
+
+func TestEntitlementVerifier_Verify(t *testing.T) {
+	t.Parallel()
+	result, err := NewEntitlementVerifier(fakeIAPClient{}, "secret").Verify(context.Background(), entitlement.Purchase{Token: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entitlements) != 1 || result.Entitlements[0].TransactionID != "1" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	_, err = NewEntitlementVerifier(fakeIAPClient{status: 21003}, "secret").Verify(context.Background(), entitlement.Purchase{Token: "1"})
+	if !errors.Is(err, ErrReceiptUnauthenticated) {
+		t.Errorf("got %v, want %v", err, ErrReceiptUnauthenticated)
+	}
+}
@@ -154,9 +154,20 @@ type (
 		SignedDate          int64                             `json:"signedDate"`
 		Data                SubscriptionNotificationV2Data    `json:"data,omitempty"`
 		Summary             SubscriptionNotificationV2Summary `json:"summary,omitempty"`
+		// ExternalPurchaseToken is only set by NotificationTypeV2ExternalPurchaseToken notifications, which have no data.
+		ExternalPurchaseToken SubscriptionNotificationV2ExternalPurchaseToken `json:"externalPurchaseToken,omitempty"`
 		jwt.RegisteredClaims
 	}
 
+	// SubscriptionNotificationV2ExternalPurchaseToken is struct
+	// https://developer.apple.com/documentation/appstoreservernotifications/externalpurchasetoken
+	SubscriptionNotificationV2ExternalPurchaseToken struct {
+		ExternalPurchaseId string `json:"externalPurchaseId"`
+		TokenCreationDate  int64  `json:"tokenCreationDate"`
+		AppAppleId         int64  `json:"appAppleId"`
+		BundleID           string `json:"bundleId"`
+	}
+
 	// SubscriptionNotificationV2Summary is struct
 	// https://developer.apple.com/documentation/appstoreservernotifications/summary
 	SubscriptionNotificationV2Summary struct {
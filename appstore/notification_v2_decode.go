@@ -0,0 +1,120 @@
+package appstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// list of errors returned when the nested JWS of a notification do not match the outer payload
+var (
+	ErrNotificationChainMismatch       = errors.New("appstore: nested JWS is not signed with the certificate chain of the notification")
+	ErrNotificationBundleIDMismatch    = errors.New("appstore: bundleId of nested JWS does not match the notification")
+	ErrNotificationEnvironmentMismatch = errors.New("appstore: environment of nested JWS does not match the notification")
+)
+
+// DecodedNotificationV2 is a notification whose signedTransactionInfo and signedRenewalInfo are verified and decoded.
+// TransactionInfo and RenewalInfo are nil when the notification does not contain them.
+type DecodedNotificationV2 struct {
+	SubscriptionNotificationV2DecodedPayload
+	TransactionInfo *JWSTransactionDecodedPayload
+	RenewalInfo     *JWSRenewalInfoDecodedPayload
+}
+
+// ParseSignedNotificationV2 verifies the signedPayload of a notification and the JWS nested in its data,
+// then returns the fully decoded notification.
+// The nested JWS must be signed with the same certificate chain as the notification and
+// their bundleId and environment must match the ones of the notification data.
+// https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2
+func (c *Client) ParseSignedNotificationV2(signedPayload string) (*DecodedNotificationV2, error) {
+	cert := Cert{Verifier: c.ChainVerifier}
+	return cert.parseSignedNotificationV2(context.Background(), signedPayload)
+}
+
+func (c *Cert) parseSignedNotificationV2(ctx context.Context, signedPayload string) (*DecodedNotificationV2, error) {
+	result := &DecodedNotificationV2{}
+	if err := c.parseJWS(ctx, signedPayload, &result.SubscriptionNotificationV2DecodedPayload); err != nil {
+		return nil, err
+	}
+	x5c, err := x5cChain(signedPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	data := result.Data
+	if data.SignedTransactionInfo != "" {
+		tx := &JWSTransactionDecodedPayload{}
+		if err := c.parseNestedJWS(ctx, string(data.SignedTransactionInfo), x5c, tx); err != nil {
+			return nil, fmt.Errorf("appstore: signedTransactionInfo: %w", err)
+		}
+		if tx.BundleId != data.BundleID {
+			return nil, fmt.Errorf("%w: got %q, want %q", ErrNotificationBundleIDMismatch, tx.BundleId, data.BundleID)
+		}
+		if string(tx.Environment) != data.Environment {
+			return nil, fmt.Errorf("%w: got %q, want %q", ErrNotificationEnvironmentMismatch, tx.Environment, data.Environment)
+		}
+		result.TransactionInfo = tx
+	}
+
+	if data.SignedRenewalInfo != "" {
+		renewal := &JWSRenewalInfoDecodedPayload{}
+		if err := c.parseNestedJWS(ctx, string(data.SignedRenewalInfo), x5c, renewal); err != nil {
+			return nil, fmt.Errorf("appstore: signedRenewalInfo: %w", err)
+		}
+		if string(renewal.Environment) != data.Environment {
+			return nil, fmt.Errorf("%w: got %q, want %q", ErrNotificationEnvironmentMismatch, renewal.Environment, data.Environment)
+		}
+		result.RenewalInfo = renewal
+	}
+
+	return result, nil
+}
+
+// parseJWS verifies the token with the certificate chain in its x5c header and decodes it into claims.
+func (c *Cert) parseJWS(ctx context.Context, tokenStr string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return c.extractPublicKeyFromToken(ctx, tokenStr)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithTimeFunc(c.verifier().CurrentTime))
+	return err
+}
+
+func (c *Cert) parseNestedJWS(ctx context.Context, tokenStr string, x5c []string, claims jwt.Claims) error {
+	nested, err := x5cChain(tokenStr)
+	if err != nil {
+		return err
+	}
+	if len(nested) != len(x5c) {
+		return ErrNotificationChainMismatch
+	}
+	for i := range x5c {
+		if nested[i] != x5c[i] {
+			return ErrNotificationChainMismatch
+		}
+	}
+	return c.parseJWS(ctx, tokenStr, claims)
+}
+
+// x5cChain returns the x5c header of the token.
+func x5cChain(tokenStr string) ([]string, error) {
+	header, _, ok := strings.Cut(tokenStr, ".")
+	if !ok {
+		return nil, jwt.ErrTokenMalformed
+	}
+	headerByte, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", jwt.ErrTokenMalformed, err)
+	}
+	var decoded SubscriptionNotificationV2JWSDecodedHeader
+	if err := json.Unmarshal(headerByte, &decoded); err != nil {
+		return nil, fmt.Errorf("%w: %v", jwt.ErrTokenMalformed, err)
+	}
+	if len(decoded.X5c) == 0 {
+		return nil, errors.New("appstore: x5c header is missing")
+	}
+	return decoded.X5c, nil
+}
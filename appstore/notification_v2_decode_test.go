@@ -0,0 +1,241 @@
+package appstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/awa/go-iap/appstore/chain"
+)
+
+// testJWSChain is a root, intermediate and leaf chain used to sign JWS in tests.
+type testJWSChain struct {
+	root, intermediate, leaf *x509.Certificate
+	key                      *ecdsa.PrivateKey
+}
+
+func newTestJWSChain(t *testing.T) *testJWSChain {
+	t.Helper()
+
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(time.Hour)
+	create := func(cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool, marker asn1.ObjectIdentifier) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		}
+		if marker != nil {
+			tmpl.ExtraExtensions = []pkix.Extension{{Id: marker, Value: []byte{0x05, 0x00}}}
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+
+	root, rootKey := create("Test Root", nil, nil, true, nil)
+	intermediate, intermediateKey := create("Test Intermediate", root, rootKey, true, chain.OIDAppleIntermediate)
+	leaf, leafKey := create("Test Leaf", intermediate, intermediateKey, false, chain.OIDAppleLeaf)
+	return &testJWSChain{root: root, intermediate: intermediate, leaf: leaf, key: leafKey}
+}
+
+func (c *testJWSChain) cert() Cert {
+	roots := x509.NewCertPool()
+	roots.AddCert(c.root)
+	return Cert{Verifier: &chain.Verifier{Roots: roots}}
+}
+
+func (c *testJWSChain) sign(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["x5c"] = []string{
+		base64.StdEncoding.EncodeToString(c.leaf.Raw),
+		base64.StdEncoding.EncodeToString(c.intermediate.Raw),
+		base64.StdEncoding.EncodeToString(c.root.Raw),
+	}
+	s, err := token.SignedString(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCert_parseSignedNotificationV2(t *testing.T) {
+	t.Parallel()
+	jws := newTestJWSChain(t)
+	cert := jws.cert()
+
+	signedTransaction := jws.sign(t, JWSTransactionDecodedPayload{
+		BundleId:              "com.example.app",
+		Environment:           Sandbox,
+		OriginalTransactionId: "1000000000000001",
+		TransactionId:         "1000000000000002",
+		ProductId:             "monthly",
+	})
+	signedRenewal := jws.sign(t, JWSRenewalInfoDecodedPayload{
+		Environment:           Sandbox,
+		OriginalTransactionId: "1000000000000001",
+		AutoRenewStatus:       On,
+	})
+	notification := func(data SubscriptionNotificationV2Data) SubscriptionNotificationV2DecodedPayload {
+		return SubscriptionNotificationV2DecodedPayload{
+			NotificationType: NotificationTypeV2DidRenew,
+			NotificationUUID: "uuid",
+			Data:             data,
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		result, err := cert.parseSignedNotificationV2(context.Background(), jws.sign(t, notification(SubscriptionNotificationV2Data{
+			BundleID:              "com.example.app",
+			Environment:           "Sandbox",
+			SignedTransactionInfo: JWSTransaction(signedTransaction),
+			SignedRenewalInfo:     JWSRenewalInfo(signedRenewal),
+		})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.NotificationType != NotificationTypeV2DidRenew {
+			t.Errorf("got notificationType %v", result.NotificationType)
+		}
+		if result.TransactionInfo == nil || result.TransactionInfo.TransactionId != "1000000000000002" {
+			t.Errorf("unexpected transaction %+v", result.TransactionInfo)
+		}
+		if result.RenewalInfo == nil || result.RenewalInfo.AutoRenewStatus != On {
+			t.Errorf("unexpected renewal %+v", result.RenewalInfo)
+		}
+	})
+
+	t.Run("without nested JWS", func(t *testing.T) {
+		result, err := cert.parseSignedNotificationV2(context.Background(), jws.sign(t, notification(SubscriptionNotificationV2Data{})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.TransactionInfo != nil || result.RenewalInfo != nil {
+			t.Errorf("unexpected nested payload %+v", result)
+		}
+	})
+
+	t.Run("bundle id mismatch", func(t *testing.T) {
+		_, err := cert.parseSignedNotificationV2(context.Background(), jws.sign(t, notification(SubscriptionNotificationV2Data{
+			BundleID:              "com.example.other",
+			Environment:           "Sandbox",
+			SignedTransactionInfo: JWSTransaction(signedTransaction),
+		})))
+		if !errors.Is(err, ErrNotificationBundleIDMismatch) {
+			t.Errorf("got %v, want %v", err, ErrNotificationBundleIDMismatch)
+		}
+	})
+
+	t.Run("environment mismatch", func(t *testing.T) {
+		_, err := cert.parseSignedNotificationV2(context.Background(), jws.sign(t, notification(SubscriptionNotificationV2Data{
+			BundleID:          "com.example.app",
+			Environment:       "Production",
+			SignedRenewalInfo: JWSRenewalInfo(signedRenewal),
+		})))
+		if !errors.Is(err, ErrNotificationEnvironmentMismatch) {
+			t.Errorf("got %v, want %v", err, ErrNotificationEnvironmentMismatch)
+		}
+	})
+
+	t.Run("chain mismatch", func(t *testing.T) {
+		other := newTestJWSChain(t)
+		roots := x509.NewCertPool()
+		roots.AddCert(jws.root)
+		roots.AddCert(other.root)
+		_, err := (&Cert{Verifier: &chain.Verifier{Roots: roots}}).parseSignedNotificationV2(context.Background(), jws.sign(t, notification(SubscriptionNotificationV2Data{
+			BundleID:              "com.example.app",
+			Environment:           "Sandbox",
+			SignedTransactionInfo: JWSTransaction(other.sign(t, JWSTransactionDecodedPayload{BundleId: "com.example.app", Environment: Sandbox})),
+		})))
+		if !errors.Is(err, ErrNotificationChainMismatch) {
+			t.Errorf("got %v, want %v", err, ErrNotificationChainMismatch)
+		}
+	})
+
+	t.Run("tampered nested JWS", func(t *testing.T) {
+		_, err := cert.parseSignedNotificationV2(context.Background(), jws.sign(t, notification(SubscriptionNotificationV2Data{
+			BundleID:              "com.example.app",
+			Environment:           "Sandbox",
+			SignedTransactionInfo: JWSTransaction(signedTransaction[:len(signedTransaction)-4] + "AAAA"),
+		})))
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			t.Errorf("got %v, want %v", err, jwt.ErrTokenSignatureInvalid)
+		}
+	})
+
+	t.Run("untrusted root", func(t *testing.T) {
+		other := newTestJWSChain(t)
+		_, err := cert.parseSignedNotificationV2(context.Background(), other.sign(t, notification(SubscriptionNotificationV2Data{})))
+		var unknownAuthority x509.UnknownAuthorityError
+		if !errors.As(err, &unknownAuthority) {
+			t.Errorf("got %v, want x509.UnknownAuthorityError", err)
+		}
+	})
+}
+
+func TestCert_parseJWS_SignedDate(t *testing.T) {
+	t.Parallel()
+	jws := newTestJWSChain(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(jws.root)
+	later := time.Now().Add(2 * time.Hour)
+	clock := func() time.Time { return later }
+	signed := func(signedDate time.Time) string {
+		return jws.sign(t, JWSTransactionDecodedPayload{TransactionId: "1000000000000001", SignedDate: signedDate.UnixMilli()})
+	}
+
+	t.Run("expired at current time", func(t *testing.T) {
+		cert := &Cert{Verifier: &chain.Verifier{Roots: roots, Now: clock}}
+		var invalid x509.CertificateInvalidError
+		if err := cert.parseJWS(context.Background(), signed(time.Now()), &JWSTransactionDecodedPayload{}); !errors.As(err, &invalid) {
+			t.Errorf("got %v, want x509.CertificateInvalidError", err)
+		}
+	})
+
+	t.Run("valid at signed date", func(t *testing.T) {
+		cert := &Cert{Verifier: &chain.Verifier{Roots: roots, Now: clock, VerifyAtSignedDate: true}}
+		tx := &JWSTransactionDecodedPayload{}
+		if err := cert.parseJWS(context.Background(), signed(time.Now()), tx); err != nil {
+			t.Fatal(err)
+		}
+		if tx.TransactionId != "1000000000000001" {
+			t.Errorf("got %v, want 1000000000000001", tx.TransactionId)
+		}
+	})
+
+	t.Run("signed in the future", func(t *testing.T) {
+		cert := &Cert{Verifier: &chain.Verifier{VerifyAtSignedDate: true, Roots: roots}}
+		if err := cert.parseJWS(context.Background(), signed(time.Now().Add(30*time.Minute)), &JWSTransactionDecodedPayload{}); !errors.Is(err, chain.ErrSignedInFuture) {
+			t.Errorf("got %v, want %v", err, chain.ErrSignedInFuture)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/awa/go-iap/appstore/chain"
+)
+
+// maxNotificationV2BodySize bounds the request body of a notification, which is a few kilobytes.
+const maxNotificationV2BodySize = 1 << 20
+
+// NotificationV2HandlerFunc handles a verified notification. Returning an error makes the App Store retry it.
+type NotificationV2HandlerFunc func(ctx context.Context, notification *DecodedNotificationV2) error
+
+// NotificationV2Handler is an http.Handler for the App Store Server Notifications V2 endpoint.
+// It verifies the signedPayload and the nested JWS, checks the bundle ID and environment,
+// then calls the callback registered for the notification type.
+// It responds 200 when the callback succeeds or no callback is registered, 400 when the notification is malformed,
+// not verified or not for the app, and 500 when the callback fails so that the App Store retries it.
+// Register the callbacks before serving requests.
+// https://developer.apple.com/documentation/appstoreservernotifications/responding_to_app_store_server_notifications
+type NotificationV2Handler struct {
+	// BundleID is the bundle ID of the app, notifications for other apps are rejected.
+	BundleID string
+	// Environment is the accepted environment, any environment is accepted when empty.
+	Environment Environment
+	// ChainVerifier verifies the x5c chain of notifications. Default trusts the Apple Root CA - G3 without revocation checks.
+	ChainVerifier *chain.Verifier
+	// Default is called for the notification types without a callback. Such notifications are acknowledged when nil.
+	Default NotificationV2HandlerFunc
+	// ErrorLog receives the reason a notification is rejected or its callback fails, when not nil.
+	ErrorLog func(err error)
+
+	handlers map[NotificationTypeV2]NotificationV2HandlerFunc
+}
+
+// NewNotificationV2Handler returns a NotificationV2Handler for the app of bundleID.
+func NewNotificationV2Handler(bundleID string) *NotificationV2Handler {
+	return &NotificationV2Handler{
+		BundleID: bundleID,
+		handlers: map[NotificationTypeV2]NotificationV2HandlerFunc{},
+	}
+}
+
+// HandleFunc registers the callback of a notification type.
+func (h *NotificationV2Handler) HandleFunc(notificationType NotificationTypeV2, fn NotificationV2HandlerFunc) {
+	if h.handlers == nil {
+		h.handlers = map[NotificationTypeV2]NotificationV2HandlerFunc{}
+	}
+	h.handlers[notificationType] = fn
+}
+
+// Decode verifies and decodes the notification in the request body, and checks it is for the app.
+func (h *NotificationV2Handler) Decode(r *http.Request) (*DecodedNotificationV2, error) {
+	var body SubscriptionNotificationV2SignedPayload
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxNotificationV2BodySize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("appstore: failed to decode notification body: %w", err)
+	}
+	cert := Cert{Verifier: h.ChainVerifier}
+	notification, err := cert.parseSignedNotificationV2(r.Context(), body.SignedPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	// notifications about renewal date extensions carry the app in their summary instead of their data,
+	// and the ones about external purchase tokens in the token, which does not tell the environment
+	bundleID, environment := notification.Data.BundleID, notification.Data.Environment
+	checkEnvironment := true
+	switch {
+	case notification.NotificationType == NotificationTypeV2ExternalPurchaseToken:
+		bundleID = notification.ExternalPurchaseToken.BundleID
+		checkEnvironment = false
+	case bundleID == "" && environment == "":
+		bundleID, environment = notification.Summary.BundleID, notification.Summary.Environment
+	}
+	if bundleID != h.BundleID {
+		return nil, fmt.Errorf("%w: got %q, want %q", ErrNotificationBundleIDMismatch, bundleID, h.BundleID)
+	}
+	if checkEnvironment && h.Environment != "" && environment != string(h.Environment) {
+		return nil, fmt.Errorf("%w: got %q, want %q", ErrNotificationEnvironmentMismatch, environment, h.Environment)
+	}
+	return notification, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *NotificationV2Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	notification, err := h.Decode(r)
+	if err != nil {
+		h.logError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	fn, ok := h.handlers[notification.NotificationType]
+	if !ok {
+		fn = h.Default
+	}
+	if fn != nil {
+		if err := fn(r.Context(), notification); err != nil {
+			h.logError(fmt.Errorf("appstore: notification %s: %w", notification.NotificationUUID, err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *NotificationV2Handler) logError(err error) {
+	if h.ErrorLog != nil {
+		h.ErrorLog(err)
+	}
+}
@@ -0,0 +1,97 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotificationV2Handler(t *testing.T) {
+	t.Parallel()
+	jws := newTestJWSChain(t)
+	other := newTestJWSChain(t)
+
+	body := func(c *testJWSChain, notification SubscriptionNotificationV2DecodedPayload) string {
+		b, err := json.Marshal(SubscriptionNotificationV2SignedPayload{SignedPayload: c.sign(t, notification)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	notification := func(notificationType NotificationTypeV2, bundleID, environment string) SubscriptionNotificationV2DecodedPayload {
+		return SubscriptionNotificationV2DecodedPayload{
+			NotificationType: notificationType,
+			NotificationUUID: "uuid",
+			Data:             SubscriptionNotificationV2Data{BundleID: bundleID, Environment: environment},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantCalled string
+	}{
+		{name: "registered type", body: body(jws, notification(NotificationTypeV2DidRenew, "com.example.app", "Sandbox")), wantStatus: http.StatusOK, wantCalled: "renew"},
+		{name: "default", body: body(jws, notification(NotificationTypeV2Subscribed, "com.example.app", "Sandbox")), wantStatus: http.StatusOK, wantCalled: "default"},
+		{name: "callback error", body: body(jws, notification(NotificationTypeV2Refund, "com.example.app", "Sandbox")), wantStatus: http.StatusInternalServerError, wantCalled: "refund"},
+		{name: "summary", body: body(jws, SubscriptionNotificationV2DecodedPayload{
+			NotificationType: NotificationTypeV2RenewalExtension,
+			Summary:          SubscriptionNotificationV2Summary{BundleID: "com.example.app", Environment: "Sandbox"},
+		}), wantStatus: http.StatusOK, wantCalled: "default"},
+		{name: "external purchase token", body: body(jws, SubscriptionNotificationV2DecodedPayload{
+			NotificationType:      NotificationTypeV2ExternalPurchaseToken,
+			Subtype:               SubTypeV2Unreported,
+			ExternalPurchaseToken: SubscriptionNotificationV2ExternalPurchaseToken{ExternalPurchaseId: "b2158121-7af9-49d4-9561-1f588205523e", BundleID: "com.example.app"},
+		}), wantStatus: http.StatusOK, wantCalled: "default"},
+		{name: "external purchase token of another app", body: body(jws, SubscriptionNotificationV2DecodedPayload{
+			NotificationType:      NotificationTypeV2ExternalPurchaseToken,
+			ExternalPurchaseToken: SubscriptionNotificationV2ExternalPurchaseToken{BundleID: "com.example.other"},
+		}), wantStatus: http.StatusBadRequest},
+		{name: "bundle id mismatch", body: body(jws, notification(NotificationTypeV2DidRenew, "com.example.other", "Sandbox")), wantStatus: http.StatusBadRequest},
+		{name: "environment mismatch", body: body(jws, notification(NotificationTypeV2DidRenew, "com.example.app", "Production")), wantStatus: http.StatusBadRequest},
+		{name: "untrusted chain", body: body(other, notification(NotificationTypeV2DidRenew, "com.example.app", "Sandbox")), wantStatus: http.StatusBadRequest},
+		{name: "malformed body", body: "{", wantStatus: http.StatusBadRequest},
+		{name: "method", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called string
+			h := NewNotificationV2Handler("com.example.app")
+			h.Environment = Sandbox
+			h.ChainVerifier = jws.cert().Verifier
+			h.HandleFunc(NotificationTypeV2DidRenew, func(ctx context.Context, n *DecodedNotificationV2) error {
+				called = "renew"
+				return nil
+			})
+			h.HandleFunc(NotificationTypeV2Refund, func(ctx context.Context, n *DecodedNotificationV2) error {
+				called = "refund"
+				return errors.New("db down")
+			})
+			h.Default = func(ctx context.Context, n *DecodedNotificationV2) error {
+				called = "default"
+				return nil
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(method, "/notifications", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("got %q called, want %q", called, tt.wantCalled)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/awa/go-iap/appstore/chain"
+)
+
+// list of errors returned by ParseReceipt
+var (
+	ErrReceiptMalformed        = errors.New("appstore: the receipt is not a valid PKCS#7 container")
+	ErrReceiptNotSigned        = errors.New("appstore: the receipt does not contain any signer")
+	ErrReceiptSignerNotFound   = errors.New("appstore: the receipt signing certificate is missing")
+	ErrReceiptInvalidSignature = errors.New("appstore: the receipt signature is invalid")
+	ErrReceiptUnsupportedAlgo  = errors.New("appstore: the receipt uses an unsupported signature algorithm")
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidDigestSHA1    = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// Receipt field types of the app receipt payload
+// https://developer.apple.com/library/archive/releasenotes/General/ValidateAppStoreReceipt/Chapters/ReceiptFields.html
+const (
+	receiptFieldReceiptType                = 0
+	receiptFieldAppItemID                  = 1
+	receiptFieldBundleID                   = 2
+	receiptFieldApplicationVersion         = 3
+	receiptFieldCreationDate               = 12
+	receiptFieldDownloadID                 = 15
+	receiptFieldVersionExternalIdentifier  = 16
+	receiptFieldInApp                      = 17
+	receiptFieldOriginalPurchaseDate       = 18
+	receiptFieldOriginalApplicationVersion = 19
+	receiptFieldExpirationDate             = 21
+	receiptFieldPreorderDate               = 32
+
+	inAppFieldQuantity              = 1701
+	inAppFieldProductID             = 1702
+	inAppFieldTransactionID         = 1703
+	inAppFieldPurchaseDate          = 1704
+	inAppFieldOriginalTransactionID = 1705
+	inAppFieldOriginalPurchaseDate  = 1706
+	inAppFieldExpiresDate           = 1708
+	inAppFieldWebOrderLineItemID    = 1711
+	inAppFieldCancellationDate      = 1712
+	inAppFieldIsTrialPeriod         = 1713
+	inAppFieldIsInIntroOfferPeriod  = 1719
+	inAppFieldPromotionalOfferID    = 1721
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type receiptAttribute struct {
+	Type    int
+	Version int
+	Value   []byte
+}
+
+// ParseReceipt decodes a base64 encoded app receipt locally, without calling the verifyReceipt endpoint.
+// The PKCS#7 signature is verified against the Apple Inc. Root certificate and the payload is decoded into Receipt.
+// The certificate chain is validated as of the receipt creation date, so receipts issued before a signing certificate expired stay valid,
+// or as of the current time when the receipt has no creation date.
+// https://developer.apple.com/documentation/appstorereceipts/validating_receipts_on_the_device
+func ParseReceipt(receiptData string) (*Receipt, error) {
+	c := Cert{}
+	return c.ParseReceipt(receiptData)
+}
+
+// ParseReceipt decodes the receipt like ParseReceipt, the certificate chain is verified against c.ReceiptRoots,
+// or the Apple Inc. Root certificate when they are nil.
+func (c *Cert) ParseReceipt(receiptData string) (*Receipt, error) {
+	raw, err := base64.StdEncoding.DecodeString(receiptData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+	der, err := berToDER(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, ErrReceiptMalformed
+	}
+
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+
+	content, err := signedContent(sd.ContentInfo.Content.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, ErrReceiptNotSigned
+	}
+
+	receipt, err := decodeReceiptPayload(content)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyAt := c.verifier().CurrentTime()
+	if receipt.CreationDateMS != "" {
+		ms, err := strconv.ParseInt(receipt.CreationDateMS, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: creation date: %v", ErrReceiptMalformed, err)
+		}
+		verifyAt = time.UnixMilli(ms)
+	}
+
+	for _, signer := range sd.SignerInfos {
+		if err := c.verifyReceiptSigner(signer, certs, content, verifyAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return receipt, nil
+}
+
+// receiptRoots returns the roots of the receipt certificate chain. Unlike the JWS, receipts are signed under the RSA Apple Inc. Root.
+func (c *Cert) receiptRoots() *x509.CertPool {
+	if c.ReceiptRoots != nil {
+		return c.ReceiptRoots
+	}
+	return chain.AppleIncRoots()
+}
+
+// signedContent extracts the eContent octets, concatenating them if the octet string is constructed.
+func signedContent(b []byte) ([]byte, error) {
+	var compound asn1.RawValue
+	if _, err := asn1.Unmarshal(b, &compound); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+	if !compound.IsCompound {
+		return compound.Bytes, nil
+	}
+
+	var content []byte
+	rest := compound.Bytes
+	for len(rest) > 0 {
+		var segment []byte
+		var err error
+		rest, err = asn1.Unmarshal(rest, &segment)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+		}
+		content = append(content, segment...)
+	}
+	return content, nil
+}
+
+func (c *Cert) verifyReceiptSigner(signer pkcs7SignerInfo, certs []*x509.Certificate, content []byte, verifyAt time.Time) error {
+	var leaf *x509.Certificate
+	for _, cert := range certs {
+		if cert.SerialNumber.Cmp(signer.IssuerAndSerialNumber.SerialNumber) == 0 &&
+			bytes.Equal(cert.RawIssuer, signer.IssuerAndSerialNumber.Issuer.FullBytes) {
+			leaf = cert
+			break
+		}
+	}
+	if leaf == nil {
+		return ErrReceiptSignerNotFound
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs {
+		if cert != leaf {
+			intermediates.AddCert(cert)
+		}
+	}
+	opts := x509.VerifyOptions{
+		Roots:         c.receiptRoots(),
+		Intermediates: intermediates,
+		CurrentTime:   verifyAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		return err
+	}
+
+	var hash crypto.Hash
+	switch {
+	case signer.DigestAlgorithm.Algorithm.Equal(oidDigestSHA1):
+		hash = crypto.SHA1
+	case signer.DigestAlgorithm.Algorithm.Equal(oidDigestSHA256):
+		hash = crypto.SHA256
+	default:
+		return ErrReceiptUnsupportedAlgo
+	}
+
+	signed := content
+	if len(signer.AuthenticatedAttributes.Bytes) > 0 {
+		digest, err := messageDigest(signer.AuthenticatedAttributes.Bytes)
+		if err != nil {
+			return err
+		}
+		h := hash.New()
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return ErrReceiptInvalidSignature
+		}
+		// The signature covers the DER encoding of the attributes as a SET OF, not the implicit [0] tag.
+		signed = append([]byte{0x31}, signer.AuthenticatedAttributes.FullBytes[1:]...)
+	}
+
+	var algo x509.SignatureAlgorithm
+	switch leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		algo = map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA1: x509.SHA1WithRSA, crypto.SHA256: x509.SHA256WithRSA}[hash]
+	case *ecdsa.PublicKey:
+		algo = map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA1: x509.ECDSAWithSHA1, crypto.SHA256: x509.ECDSAWithSHA256}[hash]
+	default:
+		return ErrReceiptUnsupportedAlgo
+	}
+
+	if err := leaf.CheckSignature(algo, signed, signer.EncryptedDigest); err != nil {
+		return fmt.Errorf("%w: %v", ErrReceiptInvalidSignature, err)
+	}
+	return nil
+}
+
+func messageDigest(attributes []byte) ([]byte, error) {
+	rest := attributes
+	for len(rest) > 0 {
+		var attr pkcs7Attribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &attr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+		}
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+		}
+		return digest, nil
+	}
+	return nil, ErrReceiptInvalidSignature
+}
+
+func decodeReceiptPayload(content []byte) (*Receipt, error) {
+	var attrs []receiptAttribute
+	if _, err := asn1.UnmarshalWithParams(content, &attrs, "set"); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+
+	receipt := &Receipt{}
+	for _, attr := range attrs {
+		var err error
+		switch attr.Type {
+		case receiptFieldReceiptType:
+			receipt.ReceiptType = asn1String(attr.Value)
+		case receiptFieldAppItemID:
+			receipt.AdamID = asn1Int(attr.Value)
+			receipt.AppItemID = NumericString(strconv.FormatInt(receipt.AdamID, 10))
+		case receiptFieldBundleID:
+			receipt.BundleID = asn1String(attr.Value)
+		case receiptFieldApplicationVersion:
+			receipt.ApplicationVersion = asn1String(attr.Value)
+		case receiptFieldCreationDate:
+			receipt.CreationDate, receipt.CreationDateMS, receipt.CreationDatePST, err = receiptDate(attr.Value)
+		case receiptFieldDownloadID:
+			receipt.DownloadID = asn1Int(attr.Value)
+		case receiptFieldVersionExternalIdentifier:
+			receipt.VersionExternalIdentifier = NumericString(strconv.FormatInt(asn1Int(attr.Value), 10))
+		case receiptFieldOriginalPurchaseDate:
+			receipt.OriginalPurchaseDate.OriginalPurchaseDate, receipt.OriginalPurchaseDateMS, receipt.OriginalPurchaseDatePST, err = receiptDate(attr.Value)
+		case receiptFieldOriginalApplicationVersion:
+			receipt.OriginalApplicationVersion = asn1String(attr.Value)
+		case receiptFieldExpirationDate:
+			receipt.ExpiresDate.ExpiresDate, receipt.ExpiresDateMS, receipt.ExpiresDatePST, err = receiptDate(attr.Value)
+		case receiptFieldPreorderDate:
+			receipt.PreorderDate.PreorderDate, receipt.PreorderDateMS, receipt.PreorderDatePST, err = receiptDate(attr.Value)
+		case receiptFieldInApp:
+			inApp, err := decodeInAppPayload(attr.Value)
+			if err != nil {
+				return nil, err
+			}
+			receipt.InApp = append(receipt.InApp, inApp)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %d: %v", ErrReceiptMalformed, attr.Type, err)
+		}
+	}
+
+	return receipt, nil
+}
+
+func decodeInAppPayload(content []byte) (InApp, error) {
+	var attrs []receiptAttribute
+	if _, err := asn1.UnmarshalWithParams(content, &attrs, "set"); err != nil {
+		return InApp{}, fmt.Errorf("%w: %v", ErrReceiptMalformed, err)
+	}
+
+	inApp := InApp{}
+	for _, attr := range attrs {
+		var err error
+		switch attr.Type {
+		case inAppFieldQuantity:
+			inApp.Quantity = strconv.FormatInt(asn1Int(attr.Value), 10)
+		case inAppFieldProductID:
+			inApp.ProductID = asn1String(attr.Value)
+		case inAppFieldTransactionID:
+			inApp.TransactionID = asn1String(attr.Value)
+		case inAppFieldPurchaseDate:
+			inApp.PurchaseDate.PurchaseDate, inApp.PurchaseDateMS, inApp.PurchaseDatePST, err = receiptDate(attr.Value)
+		case inAppFieldOriginalTransactionID:
+			inApp.OriginalTransactionID = NumericString(asn1String(attr.Value))
+		case inAppFieldOriginalPurchaseDate:
+			inApp.OriginalPurchaseDate.OriginalPurchaseDate, inApp.OriginalPurchaseDateMS, inApp.OriginalPurchaseDatePST, err = receiptDate(attr.Value)
+		case inAppFieldExpiresDate:
+			inApp.ExpiresDate.ExpiresDate, inApp.ExpiresDateMS, inApp.ExpiresDatePST, err = receiptDate(attr.Value)
+		case inAppFieldWebOrderLineItemID:
+			inApp.WebOrderLineItemID = strconv.FormatInt(asn1Int(attr.Value), 10)
+		case inAppFieldCancellationDate:
+			inApp.CancellationDate.CancellationDate, inApp.CancellationDateMS, inApp.CancellationDatePST, err = receiptDate(attr.Value)
+		case inAppFieldIsTrialPeriod:
+			inApp.IsTrialPeriod = strconv.FormatBool(asn1Int(attr.Value) == 1)
+		case inAppFieldIsInIntroOfferPeriod:
+			inApp.IsInIntroOfferPeriod = strconv.FormatBool(asn1Int(attr.Value) == 1)
+		case inAppFieldPromotionalOfferID:
+			inApp.PromotionalOfferID = asn1String(attr.Value)
+		}
+		if err != nil {
+			return InApp{}, fmt.Errorf("%w: in_app field %d: %v", ErrReceiptMalformed, attr.Type, err)
+		}
+	}
+
+	return inApp, nil
+}
+
+// asn1String decodes an UTF8String or IA5String field value. Unknown encodings decode to an empty string.
+func asn1String(b []byte) string {
+	var s string
+	if _, err := asn1.Unmarshal(b, &s); err != nil {
+		return ""
+	}
+	return s
+}
+
+// asn1Int decodes an INTEGER field value. Unknown encodings decode to zero.
+func asn1Int(b []byte) int64 {
+	var i int64
+	if _, err := asn1.Unmarshal(b, &i); err != nil {
+		return 0
+	}
+	return i
+}
+
+// receiptDate converts a RFC 3339 date field into the formats returned by the verifyReceipt endpoint.
+// An empty field converts to empty strings, a field which is not a date is an error.
+func receiptDate(b []byte) (date, ms, pst string, err error) {
+	var s string
+	if _, err := asn1.Unmarshal(b, &s); err != nil {
+		return "", "", "", err
+	}
+	if s == "" {
+		return "", "", "", nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	date = t.UTC().Format("2006-01-02 15:04:05") + " Etc/GMT"
+	ms = strconv.FormatInt(t.UnixMilli(), 10)
+	if loc, err := time.LoadLocation("America/Los_Angeles"); err == nil {
+		pst = t.In(loc).Format("2006-01-02 15:04:05") + " America/Los_Angeles"
+	}
+	return date, ms, pst, nil
+}
+
+// berToDER re-encodes indefinite length BER elements with definite lengths, as encoding/asn1 only accepts DER.
+func berToDER(b []byte) ([]byte, error) {
+	out, rest, err := berElementToDER(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after BER element")
+	}
+	return out, nil
+}
+
+func berElementToDER(b []byte) (der []byte, rest []byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, errors.New("truncated BER element")
+	}
+
+	// identifier octets
+	i := 1
+	if b[0]&0x1f == 0x1f {
+		for i < len(b) && b[i]&0x80 != 0 {
+			i++
+		}
+		i++
+	}
+	if i >= len(b) {
+		return nil, nil, errors.New("truncated BER tag")
+	}
+	tag := b[:i]
+	constructed := b[0]&0x20 != 0
+
+	// length octets
+	l := int(b[i])
+	i++
+	var content []byte
+	switch {
+	case l == 0x80:
+		if !constructed {
+			return nil, nil, errors.New("indefinite length on primitive BER element")
+		}
+		rest = b[i:]
+		for {
+			if len(rest) < 2 {
+				return nil, nil, errors.New("missing BER end-of-contents")
+			}
+			if rest[0] == 0 && rest[1] == 0 {
+				rest = rest[2:]
+				break
+			}
+			var child []byte
+			child, rest, err = berElementToDER(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			content = append(content, child...)
+		}
+		return append(append(append([]byte{}, tag...), derLength(len(content))...), content...), rest, nil
+	case l&0x80 != 0:
+		n := l & 0x7f
+		if n > 4 || i+n > len(b) {
+			return nil, nil, errors.New("invalid BER length")
+		}
+		l = 0
+		for _, c := range b[i : i+n] {
+			l = l<<8 | int(c)
+		}
+		i += n
+	}
+	if l < 0 || i+l > len(b) {
+		return nil, nil, errors.New("truncated BER content")
+	}
+	content = b[i : i+l]
+	rest = b[i+l:]
+
+	if constructed {
+		var converted []byte
+		children := content
+		for len(children) > 0 {
+			var child []byte
+			child, children, err = berElementToDER(children)
+			if err != nil {
+				return nil, nil, err
+			}
+			converted = append(converted, child...)
+		}
+		content = converted
+	}
+
+	return append(append(append([]byte{}, tag...), derLength(len(content))...), content...), rest, nil
+}
+
+func derLength(l int) []byte {
+	if l < 0x80 {
+		return []byte{byte(l)}
+	}
+	var b []byte
+	for v := l; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
@@ -0,0 +1,318 @@
+package appstore
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore/chain"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+func newTestRSACertificate(t *testing.T, cn string, parent *testCertificate, isCA bool, notBefore, notAfter time.Time) *testCertificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{cert: cert, key: key}
+}
+
+type testReceiptAttribute struct {
+	Type    int
+	Version int
+	Value   []byte
+}
+
+func testReceiptField(t *testing.T, typ int, value interface{}, params string) testReceiptAttribute {
+	t.Helper()
+	b, err := asn1.MarshalWithParams(value, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testReceiptAttribute{Type: typ, Version: 1, Value: b}
+}
+
+func testReceiptPayload(t *testing.T) []byte {
+	t.Helper()
+	return testReceiptPayloadCreatedAt(t, testReceiptField(t, receiptFieldCreationDate, "2020-01-02T03:04:05Z", "ia5"))
+}
+
+func testReceiptPayloadCreatedAt(t *testing.T, creationDate testReceiptAttribute) []byte {
+	t.Helper()
+	inApp, err := asn1.MarshalWithParams([]testReceiptAttribute{
+		testReceiptField(t, inAppFieldQuantity, 1, ""),
+		testReceiptField(t, inAppFieldProductID, "com.example.monthly", "utf8"),
+		testReceiptField(t, inAppFieldTransactionID, "1000000000000002", "utf8"),
+		testReceiptField(t, inAppFieldOriginalTransactionID, "1000000000000001", "utf8"),
+		testReceiptField(t, inAppFieldPurchaseDate, "2020-01-01T00:00:00Z", "ia5"),
+		testReceiptField(t, inAppFieldExpiresDate, "2020-02-01T00:00:00Z", "ia5"),
+		testReceiptField(t, inAppFieldWebOrderLineItemID, 20000000001, ""),
+		testReceiptField(t, inAppFieldIsTrialPeriod, 1, ""),
+		testReceiptField(t, inAppFieldIsInIntroOfferPeriod, 0, ""),
+	}, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := asn1.MarshalWithParams([]testReceiptAttribute{
+		testReceiptField(t, receiptFieldReceiptType, "ProductionSandbox", "utf8"),
+		testReceiptField(t, receiptFieldAppItemID, 123456789, ""),
+		testReceiptField(t, receiptFieldBundleID, "com.example.app", "utf8"),
+		testReceiptField(t, receiptFieldApplicationVersion, "42", "utf8"),
+		creationDate,
+		testReceiptField(t, receiptFieldOriginalApplicationVersion, "1.0", "utf8"),
+		{Type: receiptFieldInApp, Version: 1, Value: inApp},
+	}, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+type testContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type testSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      testContentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []testSignerInfo `asn1:"set"`
+}
+
+type testSignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type testAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+func testSignReceipt(t *testing.T, payload []byte, leaf *testCertificate, intermediates []*x509.Certificate, withAttributes bool) string {
+	t.Helper()
+
+	digest := sha256.Sum256(payload)
+	signer := testSignerInfo{
+		Version:                   1,
+		IssuerAndSerialNumber:     pkcs7IssuerAndSerial{Issuer: asn1.RawValue{FullBytes: leaf.cert.RawIssuer}, SerialNumber: leaf.cert.SerialNumber},
+		DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256},
+		DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}},
+	}
+
+	signed := digest[:]
+	if withAttributes {
+		value, _ := asn1.Marshal(digest[:])
+		attrs, err := asn1.MarshalWithParams([]testAttribute{
+			{Type: oidMessageDigest, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value}},
+		}, "set")
+		if err != nil {
+			t.Fatal(err)
+		}
+		attrsDigest := sha256.Sum256(attrs)
+		signed = attrsDigest[:]
+		signer.AuthenticatedAttributes = asn1.RawValue{FullBytes: append([]byte{0xa0}, attrs[1:]...)}
+	}
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, leaf.key, crypto.SHA256, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer.EncryptedDigest = sig
+
+	content, _ := asn1.Marshal(payload)
+	var certs []byte
+	for _, c := range append([]*x509.Certificate{leaf.cert}, intermediates...) {
+		certs = append(certs, c.Raw...)
+	}
+	sd, err := asn1.Marshal(testSignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidDigestSHA256}},
+		ContentInfo: testContentInfo{
+			ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1},
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+		},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos:  []testSignerInfo{signer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := asn1.Marshal(testContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(info)
+}
+
+func TestCert_ParseReceipt(t *testing.T) {
+	// The certificates expired long ago, the chain must be validated as of the receipt creation date.
+	notBefore := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := newTestRSACertificate(t, "Test Root", nil, true, notBefore, notAfter)
+	intermediate := newTestRSACertificate(t, "Test Intermediate", root, true, notBefore, notAfter)
+	leaf := newTestRSACertificate(t, "Test Receipt Signing", intermediate, false, notBefore, notAfter)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+	cert := Cert{ReceiptRoots: roots}
+	payload := testReceiptPayload(t)
+
+	for _, withAttributes := range []bool{false, true} {
+		receipt, err := cert.ParseReceipt(testSignReceipt(t, payload, leaf, []*x509.Certificate{intermediate.cert}, withAttributes))
+		if err != nil {
+			t.Fatalf("withAttributes=%v: %v", withAttributes, err)
+		}
+		if receipt.BundleID != "com.example.app" || receipt.ReceiptType != "ProductionSandbox" || receipt.AdamID != 123456789 {
+			t.Errorf("unexpected receipt %+v", receipt)
+		}
+		if receipt.CreationDate != "2020-01-02 03:04:05 Etc/GMT" || receipt.CreationDateMS != "1577934245000" {
+			t.Errorf("unexpected creation date %+v", receipt.ReceiptCreationDate)
+		}
+		if len(receipt.InApp) != 1 {
+			t.Fatalf("got %d in_app, want 1", len(receipt.InApp))
+		}
+		inApp := receipt.InApp[0]
+		if inApp.ProductID != "com.example.monthly" || inApp.TransactionID != "1000000000000002" ||
+			inApp.OriginalTransactionID != "1000000000000001" || inApp.Quantity != "1" ||
+			inApp.WebOrderLineItemID != "20000000001" || inApp.IsTrialPeriod != "true" ||
+			inApp.IsInIntroOfferPeriod != "false" || inApp.ExpiresDateMS != "1580515200000" {
+			t.Errorf("unexpected in_app %+v", inApp)
+		}
+	}
+
+	t.Run("tampered payload", func(t *testing.T) {
+		receiptData := testSignReceipt(t, payload, leaf, []*x509.Certificate{intermediate.cert}, true)
+		raw, _ := base64.StdEncoding.DecodeString(receiptData)
+		for i := range raw {
+			if string(raw[i:i+15]) == "com.example.app" {
+				copy(raw[i:], "com.evil.apppp")
+				break
+			}
+		}
+		_, err := cert.ParseReceipt(base64.StdEncoding.EncodeToString(raw))
+		if !errors.Is(err, ErrReceiptInvalidSignature) {
+			t.Errorf("got %v, want %v", err, ErrReceiptInvalidSignature)
+		}
+	})
+
+	t.Run("untrusted root", func(t *testing.T) {
+		other := newTestRSACertificate(t, "Other Root", nil, true, notBefore, notAfter)
+		pool := x509.NewCertPool()
+		pool.AddCert(other.cert)
+		untrusted := Cert{ReceiptRoots: pool}
+		_, err := untrusted.ParseReceipt(testSignReceipt(t, payload, leaf, []*x509.Certificate{intermediate.cert}, false))
+		var unknownAuthority x509.UnknownAuthorityError
+		if !errors.As(err, &unknownAuthority) {
+			t.Errorf("got %v, want x509.UnknownAuthorityError", err)
+		}
+	})
+
+	t.Run("verifier roots", func(t *testing.T) {
+		// the roots of the JWS do not apply to receipts
+		receiptData := testSignReceipt(t, payload, leaf, []*x509.Certificate{intermediate.cert}, false)
+		jwsRoots := Cert{Verifier: &chain.Verifier{Roots: roots}}
+		var unknownAuthority x509.UnknownAuthorityError
+		if _, err := jwsRoots.ParseReceipt(receiptData); !errors.As(err, &unknownAuthority) {
+			t.Errorf("got %v, want x509.UnknownAuthorityError", err)
+		}
+		both := Cert{Verifier: &chain.Verifier{Roots: chain.AppleRoots()}, ReceiptRoots: roots}
+		if _, err := both.ParseReceipt(receiptData); err != nil {
+			t.Errorf("got %v, want nil", err)
+		}
+	})
+
+	t.Run("default root", func(t *testing.T) {
+		_, err := (&Cert{}).ParseReceipt(testSignReceipt(t, payload, leaf, []*x509.Certificate{intermediate.cert}, false))
+		var unknownAuthority x509.UnknownAuthorityError
+		if !errors.As(err, &unknownAuthority) {
+			t.Errorf("got %v, want x509.UnknownAuthorityError", err)
+		}
+	})
+
+	t.Run("malformed creation date", func(t *testing.T) {
+		for _, creationDate := range []testReceiptAttribute{
+			testReceiptField(t, receiptFieldCreationDate, "2020-01-02", "ia5"),
+			testReceiptField(t, receiptFieldCreationDate, 1577934245000, ""),
+		} {
+			malformed := testReceiptPayloadCreatedAt(t, creationDate)
+			_, err := cert.ParseReceipt(testSignReceipt(t, malformed, leaf, []*x509.Certificate{intermediate.cert}, false))
+			if !errors.Is(err, ErrReceiptMalformed) {
+				t.Errorf("got %v, want %v", err, ErrReceiptMalformed)
+			}
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := cert.ParseReceipt(base64.StdEncoding.EncodeToString([]byte("dummy data")))
+		if !errors.Is(err, ErrReceiptMalformed) {
+			t.Errorf("got %v, want %v", err, ErrReceiptMalformed)
+		}
+	})
+}
+
+func TestBerToDER(t *testing.T) {
+	// SEQUENCE (indefinite) { OCTET STRING (constructed, indefinite) { "ab", "c" } }
+	ber := []byte{0x30, 0x80, 0x24, 0x80, 0x04, 0x02, 'a', 'b', 0x04, 0x01, 'c', 0x00, 0x00, 0x00, 0x00}
+	der, err := berToDER(ber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil {
+		t.Fatal(err)
+	}
+	content, err := signedContent(seq.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "abc" {
+		t.Errorf("got %q, want %q", content, "abc")
+	}
+}
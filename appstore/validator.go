@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/awa/go-iap/appstore/chain"
 )
 
 const (
@@ -36,6 +38,8 @@ type IAPClient interface {
 type Client struct {
 	ProductionURL string
 	SandboxURL    string
+	// ChainVerifier verifies the x5c chain of notifications. Default trusts the Apple Root CA - G3 without revocation checks.
+	ChainVerifier *chain.Verifier
 	httpCli       *http.Client
 }
 
@@ -194,11 +198,11 @@ func (c *Client) parseResponse(resp *http.Response, result interface{}, ctx cont
 
 // ParseNotificationV2 parse notification from App Store Server
 func (c *Client) ParseNotificationV2(tokenStr string, result *jwt.Token) error {
-	cert := Cert{}
+	cert := Cert{Verifier: c.ChainVerifier}
 
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		return cert.ExtractPublicKeyFromToken(tokenStr)
-	})
+	}, jwt.WithTimeFunc(cert.verifier().CurrentTime))
 	if token != nil {
 		*result = *token
 	}
@@ -207,10 +211,10 @@ func (c *Client) ParseNotificationV2(tokenStr string, result *jwt.Token) error {
 
 // ParseNotificationV2WithClaim parse notification from App Store Server
 func (c *Client) ParseNotificationV2WithClaim(tokenStr string, result jwt.Claims) error {
-	cert := Cert{}
+	cert := Cert{Verifier: c.ChainVerifier}
 
 	_, err := jwt.ParseWithClaims(tokenStr, result, func(token *jwt.Token) (interface{}, error) {
 		return cert.ExtractPublicKeyFromToken(tokenStr)
-	})
+	}, jwt.WithTimeFunc(cert.verifier().CurrentTime))
 	return err
 }
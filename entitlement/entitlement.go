@@ -0,0 +1,93 @@
+// Package entitlement provides a store independent view of what a user has purchased.
+// Each store package converts its own responses into an Entitlement so that the
+// access logic can be written once for every store.
+package entitlement
+
+import "time"
+
+// Store identifies the store a purchase was made in.
+type Store string
+
+// list of Store
+const (
+	AppStore       Store = "appstore"
+	PlayStore      Store = "playstore"
+	Amazon         Store = "amazon"
+	HMS            Store = "hms"
+	MicrosoftStore Store = "microsoftstore"
+)
+
+// State is the normalized state of an entitlement.
+type State string
+
+// list of State
+const (
+	// StateActive means the user has access and the purchase is in good standing.
+	StateActive State = "active"
+	// StateGracePeriod means the renewal failed but the store still grants access.
+	StateGracePeriod State = "grace_period"
+	// StateBillingRetry means the renewal failed and the store is retrying the payment, the user has no access.
+	StateBillingRetry State = "billing_retry"
+	// StatePaused means the user paused the subscription.
+	StatePaused State = "paused"
+	// StatePending means the payment has not completed yet.
+	StatePending State = "pending"
+	// StateExpired means the subscription period ended without a renewal.
+	StateExpired State = "expired"
+	// StateRevoked means the purchase was refunded or revoked by the store.
+	StateRevoked State = "revoked"
+)
+
+// Entitlement is a normalized purchase.
+// Time fields are the zero time when the store does not provide them.
+type Entitlement struct {
+	Store     Store
+	ProductID string
+	// OriginalID stays the same across the renewals of a subscription.
+	OriginalID string
+	// TransactionID identifies the latest transaction or order of the purchase.
+	TransactionID string
+	State         State
+
+	PeriodStart time.Time
+	// PeriodEnd is zero for purchases which do not expire.
+	PeriodEnd time.Time
+
+	AutoRenew  bool
+	Trial      bool
+	IntroOffer bool
+
+	Revoked   bool
+	RevokedAt time.Time
+
+	// PriceMicros is the price in micro units of Currency, 0 when unknown.
+	PriceMicros int64
+	Currency    string
+}
+
+// HasAccess reports whether the user should be granted the product.
+func (e Entitlement) HasAccess() bool {
+	return e.State == StateActive || e.State == StateGracePeriod
+}
+
+// IsSubscription reports whether the entitlement has a period end.
+func (e Entitlement) IsSubscription() bool {
+	return !e.PeriodEnd.IsZero()
+}
+
+// ExpiredAt returns StateExpired when end is not zero and not after now, StateActive otherwise.
+// It is a helper for converters whose store does not report a state.
+func ExpiredAt(end, now time.Time) State {
+	if !end.IsZero() && !end.After(now) {
+		return StateExpired
+	}
+	return StateActive
+}
+
+// FromMillis converts milliseconds since the epoch to time.Time, 0 is converted to the zero time.
+func FromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,42 @@
+package entitlement
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiredAt(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	tests := []struct {
+		end      time.Time
+		expected State
+	}{
+		{time.Time{}, StateActive},
+		{now.Add(time.Hour), StateActive},
+		{now, StateExpired},
+		{now.Add(-time.Hour), StateExpired},
+	}
+	for _, tt := range tests {
+		if got := ExpiredAt(tt.end, now); got != tt.expected {
+			t.Errorf("ExpiredAt(%v): got %v, want %v", tt.end, got, tt.expected)
+		}
+	}
+}
+
+func TestEntitlement_HasAccess(t *testing.T) {
+	t.Parallel()
+	for state, expected := range map[State]bool{
+		StateActive:       true,
+		StateGracePeriod:  true,
+		StateBillingRetry: false,
+		StatePaused:       false,
+		StatePending:      false,
+		StateExpired:      false,
+		StateRevoked:      false,
+	} {
+		if got := (Entitlement{State: state}).HasAccess(); got != expected {
+			t.Errorf("%v: got %v, want %v", state, got, expected)
+		}
+	}
+}
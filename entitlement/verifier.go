@@ -0,0 +1,79 @@
+package entitlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnsupportedStore is returned by Router when no Verifier is registered for the store of the purchase.
+var ErrUnsupportedStore = errors.New("entitlement: unsupported store")
+
+// Purchase is a store tagged purchase sent by a client app.
+type Purchase struct {
+	Store Store
+	// Token is the receipt data, the purchase token or the receipt ID depending on the store.
+	// The Microsoft Store has no token, it is sent as the localTicketReference of the query.
+	Token string
+	// ProductID is required by Google Play and HMS.
+	ProductID string
+	// PackageName is required by Google Play.
+	PackageName string
+	// UserID is the Amazon user ID or the Microsoft Store ID key of the user.
+	UserID string
+	// Subscription tells whether the token belongs to a subscription, it is used by Google Play and HMS.
+	Subscription bool
+}
+
+// Result is the normalized result of a verification.
+type Result struct {
+	Entitlements []Entitlement
+	// Raw is the response of the store, e.g. *appstore.IAPResponse.
+	Raw interface{}
+}
+
+// Verifier verifies a purchase with the backend of a store.
+type Verifier interface {
+	Verify(ctx context.Context, purchase Purchase) (*Result, error)
+}
+
+// VerifierFunc is an adapter to allow the use of ordinary functions as a Verifier.
+type VerifierFunc func(ctx context.Context, purchase Purchase) (*Result, error)
+
+// Verify calls f(ctx, purchase).
+func (f VerifierFunc) Verify(ctx context.Context, purchase Purchase) (*Result, error) {
+	return f(ctx, purchase)
+}
+
+// Router dispatches a purchase to the Verifier registered for its store.
+type Router struct {
+	mu        sync.RWMutex
+	verifiers map[Store]Verifier
+}
+
+// Verify that Router implements Verifier
+var _ Verifier = (*Router)(nil)
+
+// NewRouter creates a router without any verifier.
+func NewRouter() *Router {
+	return &Router{verifiers: make(map[Store]Verifier)}
+}
+
+// Register sets the verifier of the store, it replaces the previously registered one.
+func (r *Router) Register(store Store, v Verifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.verifiers[store] = v
+}
+
+// Verify sends the purchase to the verifier of its store.
+func (r *Router) Verify(ctx context.Context, purchase Purchase) (*Result, error) {
+	r.mu.RLock()
+	v, ok := r.verifiers[purchase.Store]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedStore, purchase.Store)
+	}
+	return v.Verify(ctx, purchase)
+}
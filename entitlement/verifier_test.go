@@ -0,0 +1,35 @@
+package entitlement
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRouter_Verify(t *testing.T) {
+	t.Parallel()
+	router := NewRouter()
+	router.Register(AppStore, VerifierFunc(func(ctx context.Context, purchase Purchase) (*Result, error) {
+		return &Result{Entitlements: []Entitlement{{Store: AppStore, ProductID: purchase.ProductID}}}, nil
+	}))
+	failure := errors.New("backend failure")
+	router.Register(PlayStore, VerifierFunc(func(ctx context.Context, purchase Purchase) (*Result, error) {
+		return nil, failure
+	}))
+
+	result, err := router.Verify(context.Background(), Purchase{Store: AppStore, ProductID: "monthly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entitlements) != 1 || result.Entitlements[0].ProductID != "monthly" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if _, err := router.Verify(context.Background(), Purchase{Store: PlayStore}); !errors.Is(err, failure) {
+		t.Errorf("got %v, want %v", err, failure)
+	}
+
+	if _, err := router.Verify(context.Background(), Purchase{Store: Amazon}); !errors.Is(err, ErrUnsupportedStore) {
+		t.Errorf("got %v, want %v", err, ErrUnsupportedStore)
+	}
+}
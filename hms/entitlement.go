@@ -0,0 +1,99 @@
+package hms
+
+import (
+	"context"
+	"time"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+// Entitlement converts the purchase data into a normalized entitlement as of now.
+// https://developer.huawei.com/consumer/en/doc/HMSCore-References/server-data-model-0000001050986133
+func (d InAppPurchaseData) Entitlement(now time.Time) entitlement.Entitlement {
+	e := entitlement.Entitlement{
+		Store:         entitlement.HMS,
+		ProductID:     d.ProductID,
+		OriginalID:    d.OrderID,
+		TransactionID: d.OrderID,
+		PeriodStart:   entitlement.FromMillis(d.PurchaseTime),
+		AutoRenew:     d.AutoRenewing,
+		// price is multiplied by 100
+		PriceMicros: d.Price * 10000,
+		Currency:    d.Currency,
+	}
+	if e.PeriodStart.IsZero() {
+		e.PeriodStart = entitlement.FromMillis(d.PurchaseTimeMillis)
+	}
+
+	switch d.PurchaseState {
+	case InAppPurchaseDataPurchaseStateInitialized:
+		e.State = entitlement.StatePending
+		return e
+	case InAppPurchaseDataPurchaseStateCanceled, InAppPurchaseDataPurchaseStateRefunded:
+		e.State = entitlement.StateRevoked
+		e.Revoked = true
+		e.RevokedAt = entitlement.FromMillis(d.CancelTime)
+		return e
+	}
+
+	if d.Kind != InAppPurchaseDataKindSubscription {
+		e.State = entitlement.StateActive
+		return e
+	}
+
+	if d.SubscriptionID != "" {
+		e.OriginalID = d.SubscriptionID
+	}
+	e.PeriodEnd = entitlement.FromMillis(d.ExpirationDate)
+	e.Trial = d.TrialFlag == InAppPurchaseDataTrialFlagYes
+	e.IntroOffer = d.IntroductoryFlag == InAppPurchaseDataIntroductoryFlagYes
+
+	switch {
+	case d.CancelTime != 0:
+		// a subscription with cancelTime has been revoked and refunded
+		e.State = entitlement.StateRevoked
+		e.Revoked = true
+		e.RevokedAt = entitlement.FromMillis(d.CancelTime)
+	case d.SubIsValid:
+		e.State = entitlement.StateActive
+	case d.GraceExpirationTime != 0 && entitlement.FromMillis(d.GraceExpirationTime).After(now):
+		e.State = entitlement.StateGracePeriod
+	case d.RetryFlag == InAppPurchaseDataRetryFlagYes:
+		e.State = entitlement.StateBillingRetry
+	default:
+		e.State = entitlement.StateExpired
+	}
+	return e
+}
+
+// EntitlementVerifier verifies HMS purchase tokens as an entitlement.Verifier.
+type EntitlementVerifier struct {
+	client      *Client
+	accountFlag int64
+}
+
+// Verify that EntitlementVerifier implements entitlement.Verifier
+var _ entitlement.Verifier = (*EntitlementVerifier)(nil)
+
+// NewEntitlementVerifier creates a verifier from the client, accountFlag is one of InAppPurchaseDataAccountFlag.
+func NewEntitlementVerifier(client *Client, accountFlag int64) *EntitlementVerifier {
+	return &EntitlementVerifier{client: client, accountFlag: accountFlag}
+}
+
+// Verify verifies purchase.Token with the subscription API when purchase.Subscription is true,
+// with the order API otherwise. The result contains InAppPurchaseData as Raw.
+func (v *EntitlementVerifier) Verify(ctx context.Context, purchase entitlement.Purchase) (*entitlement.Result, error) {
+	var (
+		data InAppPurchaseData
+		err  error
+	)
+	if purchase.Subscription {
+		data, err = v.client.VerifySubscription(ctx, purchase.Token, purchase.ProductID, v.accountFlag)
+	} else {
+		data, err = v.client.VerifyOrder(ctx, purchase.Token, purchase.ProductID, v.accountFlag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entitlement.Result{Entitlements: []entitlement.Entitlement{data.Entitlement(time.Now())}, Raw: data}, nil
+}
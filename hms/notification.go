@@ -95,7 +95,7 @@ type StatusUpdateNotification struct {
 	ExpirationIntent int64 `json:"expirationIntent,omitempty"`
 }
 
-// Constants for StatusUpdateNotification.NotificationType
+// Constants for StatusUpdateNotification.NotificationType, see SubscriptionNotificationTypeInitialBuy etc. for the typed ones
 // https://developer.huawei.com/consumer/en/doc/HMSCore-References/api-notifications-about-subscription-events-0000001050706084#EN-US_TOPIC_0000001050706084__section18290165220716
 const (
 	NotificationTypeInitialBuy           int64 = 0
@@ -0,0 +1,250 @@
+package hms
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Signature algorithms declared by the signatureAlgorithm field of the notifications.
+// https://developer.huawei.com/consumer/en/doc/HMSCore-Guides/verifying-signature-returned-result-0000001050033088
+const (
+	SignatureAlgorithmSHA256WithRSA    = "SHA256WithRSA"
+	SignatureAlgorithmSHA256WithRSAPSS = "SHA256WithRSA/PSS"
+)
+
+// Event types of SubscriptionNotificationV2.EventType.
+const (
+	EventTypeOrder        = "ORDER"
+	EventTypeSubscription = "SUBSCRIPTION"
+)
+
+// list of errors returned while parsing a notification
+var (
+	ErrUnsupportedSignatureAlgorithm = errors.New("hms: unsupported signature algorithm")
+	ErrInvalidNotification           = errors.New("hms: notification is neither a V1 nor a V2 notification")
+)
+
+// SubscriptionNotificationType is the type of a subscription notification.
+// https://developer.huawei.com/consumer/en/doc/HMSCore-References/api-notifications-about-subscription-events-0000001050706084#EN-US_TOPIC_0000001050706084__section18290165220716
+type SubscriptionNotificationType int64
+
+// Constants for DecodedSubscriptionNotification.Type, the typed NotificationTypeInitialBuy etc.
+const (
+	SubscriptionNotificationTypeInitialBuy           SubscriptionNotificationType = 0
+	SubscriptionNotificationTypeCancel               SubscriptionNotificationType = 1
+	SubscriptionNotificationTypeRenewal              SubscriptionNotificationType = 2
+	SubscriptionNotificationTypeInteractiveRenewal   SubscriptionNotificationType = 3
+	SubscriptionNotificationTypeNewRenewalPref       SubscriptionNotificationType = 4
+	SubscriptionNotificationTypeRenewalStopped       SubscriptionNotificationType = 5
+	SubscriptionNotificationTypeRenewalRestored      SubscriptionNotificationType = 6
+	SubscriptionNotificationTypeRenewalRecurring     SubscriptionNotificationType = 7
+	SubscriptionNotificationTypeInGracePeriod        SubscriptionNotificationType = 8
+	SubscriptionNotificationTypeOnHold               SubscriptionNotificationType = 9
+	SubscriptionNotificationTypePaused               SubscriptionNotificationType = 10
+	SubscriptionNotificationTypePausePlanChanged     SubscriptionNotificationType = 11
+	SubscriptionNotificationTypePriceChangeConfirmed SubscriptionNotificationType = 12
+	SubscriptionNotificationTypeDeferred             SubscriptionNotificationType = 13
+)
+
+// String returns the name of the notification type as documented by Huawei.
+func (t SubscriptionNotificationType) String() string {
+	switch t {
+	case SubscriptionNotificationTypeInitialBuy:
+		return "INITIAL_BUY"
+	case SubscriptionNotificationTypeCancel:
+		return "CANCEL"
+	case SubscriptionNotificationTypeRenewal:
+		return "RENEWAL"
+	case SubscriptionNotificationTypeInteractiveRenewal:
+		return "INTERACTIVE_RENEWAL"
+	case SubscriptionNotificationTypeNewRenewalPref:
+		return "NEW_RENEWAL_PREF"
+	case SubscriptionNotificationTypeRenewalStopped:
+		return "RENEWAL_STOPPED"
+	case SubscriptionNotificationTypeRenewalRestored:
+		return "RENEWAL_RESTORED"
+	case SubscriptionNotificationTypeRenewalRecurring:
+		return "RENEWAL_RECURRING"
+	case SubscriptionNotificationTypeInGracePeriod:
+		return "IN_GRACE_PERIOD"
+	case SubscriptionNotificationTypeOnHold:
+		return "ON_HOLD"
+	case SubscriptionNotificationTypePaused:
+		return "PAUSED"
+	case SubscriptionNotificationTypePausePlanChanged:
+		return "PAUSE_PLAN_CHANGED"
+	case SubscriptionNotificationTypePriceChangeConfirmed:
+		return "PRICE_CHANGE_CONFIRMED"
+	case SubscriptionNotificationTypeDeferred:
+		return "DEFERRED"
+	}
+	return fmt.Sprintf("SubscriptionNotificationType(%d)", int64(t))
+}
+
+// OrderNotificationType is the type of an order notification.
+// https://developer.huawei.com/consumer/en/doc/HMSCore-References/api-notifications-about-subscription-events-v2-0000001385268541
+type OrderNotificationType int64
+
+// Constants for OrderNotification.NotificationType
+const (
+	OrderNotificationTypePaymentSuccess OrderNotificationType = 1
+	OrderNotificationTypeRefundSuccess  OrderNotificationType = 2
+)
+
+// String returns the name of the notification type.
+func (t OrderNotificationType) String() string {
+	switch t {
+	case OrderNotificationTypePaymentSuccess:
+		return "PAYMENT_SUCCESS"
+	case OrderNotificationTypeRefundSuccess:
+		return "REFUND_SUCCESS"
+	}
+	return fmt.Sprintf("OrderNotificationType(%d)", int64(t))
+}
+
+// DecodedSubscriptionNotification is a verified subscription notification.
+type DecodedSubscriptionNotification struct {
+	// Type is the typed StatusUpdateNotification.NotificationType.
+	Type SubscriptionNotificationType
+	StatusUpdateNotification
+}
+
+// DecodedOrderNotification is an order notification.
+type DecodedOrderNotification struct {
+	// Type is the typed OrderNotification.NotificationType.
+	Type OrderNotificationType
+	OrderNotification
+}
+
+// DecodedNotification is a notification returned by ParseNotification.
+// Exactly one of Subscription and Order is set.
+type DecodedNotification struct {
+	// Version is "v2" for the V2 notifications, and empty or "v2" for the V1 notifications.
+	Version string
+	// EventType is EventTypeOrder or EventTypeSubscription, always EventTypeSubscription for V1 notifications.
+	EventType string
+	// NotifyTime is the time the notification is sent in milliseconds, only set by V2 notifications.
+	NotifyTime int64
+	// ApplicationID is the app ID, from the status update of V1 notifications.
+	ApplicationID string
+
+	Subscription *DecodedSubscriptionNotification
+	// Order is not signed by Huawei, verify the purchase with Client.VerifyOrder before granting anything.
+	Order *DecodedOrderNotification
+}
+
+// rawNotification holds the fields of both the V1 and V2 notification bodies.
+type rawNotification struct {
+	SubscriptionNotification
+	EventType         string             `json:"eventType"`
+	NotifyTime        int64              `json:"notifyTime"`
+	ApplicationID     string             `json:"applicationId"`
+	OrderNotification *OrderNotification `json:"orderNotification"`
+	SubNotification   *SubNotification   `json:"subNotification"`
+}
+
+// ParseNotification verifies and decodes a V1 or V2 notification body with the IAP public key of the app.
+// The status update of subscription notifications is verified with the declared signature algorithm,
+// SHA256WithRSA when none is declared.
+//
+// https://developer.huawei.com/consumer/en/doc/HMSCore-References/api-notifications-about-subscription-events-0000001050706084
+// https://developer.huawei.com/consumer/en/doc/HMSCore-References/api-notifications-about-subscription-events-v2-0000001385268541
+func ParseNotification(body []byte, base64EncodedPublicKey string) (*DecodedNotification, error) {
+	var raw rawNotification
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("hms: failed to decode notification: %w", err)
+	}
+
+	switch {
+	case raw.StatusUpdateNotification != "":
+		// V1 notification
+		sub, err := decodeStatusUpdate(base64EncodedPublicKey, raw.StatusUpdateNotification, raw.NotifycationSignature, raw.SignatureAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedNotification{
+			Version:       raw.Version,
+			EventType:     EventTypeSubscription,
+			ApplicationID: sub.ApplicationID,
+			Subscription:  sub,
+		}, nil
+	case raw.EventType == EventTypeSubscription && raw.SubNotification != nil:
+		n := raw.SubNotification
+		sub, err := decodeStatusUpdate(base64EncodedPublicKey, n.StatusUpdateNotification, n.NotificationSignature, n.SignatureAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedNotification{
+			Version:       raw.Version,
+			EventType:     raw.EventType,
+			NotifyTime:    raw.NotifyTime,
+			ApplicationID: raw.ApplicationID,
+			Subscription:  sub,
+		}, nil
+	case raw.EventType == EventTypeOrder && raw.OrderNotification != nil:
+		return &DecodedNotification{
+			Version:       raw.Version,
+			EventType:     raw.EventType,
+			NotifyTime:    raw.NotifyTime,
+			ApplicationID: raw.ApplicationID,
+			Order: &DecodedOrderNotification{
+				Type:              OrderNotificationType(raw.OrderNotification.NotificationType),
+				OrderNotification: *raw.OrderNotification,
+			},
+		}, nil
+	}
+	return nil, ErrInvalidNotification
+}
+
+// decodeStatusUpdate verifies and decodes the statusUpdateNotification JSON string.
+func decodeStatusUpdate(base64EncodedPublicKey, data, signature, algorithm string) (*DecodedSubscriptionNotification, error) {
+	if err := VerifySignatureWithAlgorithm(base64EncodedPublicKey, data, signature, algorithm); err != nil {
+		return nil, err
+	}
+	var n StatusUpdateNotification
+	if err := json.Unmarshal([]byte(data), &n); err != nil {
+		return nil, fmt.Errorf("hms: failed to decode status update notification: %w", err)
+	}
+	return &DecodedSubscriptionNotification{
+		Type:                     SubscriptionNotificationType(n.NotificationType),
+		StatusUpdateNotification: n,
+	}, nil
+}
+
+// VerifySignatureWithAlgorithm verifies the signature of data with the IAP public key and the signature algorithm,
+// SignatureAlgorithmSHA256WithRSA or SignatureAlgorithmSHA256WithRSAPSS. An empty algorithm means SHA256WithRSA.
+//
+// Document: https://developer.huawei.com/consumer/en/doc/development/HMSCore-Guides/verifying-signature-returned-result-0000001050033088
+func VerifySignatureWithAlgorithm(base64EncodedPublicKey, data, signature, algorithm string) error {
+	publicKeyByte, err := base64.StdEncoding.DecodeString(base64EncodedPublicKey)
+	if err != nil {
+		return err
+	}
+	pub, err := x509.ParsePKIXPublicKey(publicKeyByte)
+	if err != nil {
+		return err
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("hms: public key is not an RSA key")
+	}
+	signatureByte, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(data))
+
+	switch algorithm {
+	case "", SignatureAlgorithmSHA256WithRSA:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signatureByte)
+	case SignatureAlgorithmSHA256WithRSAPSS:
+		return rsa.VerifyPSS(key, crypto.SHA256, hashed[:], signatureByte, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedSignatureAlgorithm, algorithm)
+}
@@ -0,0 +1,191 @@
+package hms
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func newTestNotificationKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+func signTestNotification(t *testing.T, key *rsa.PrivateKey, data, algorithm string) string {
+	t.Helper()
+	hashed := sha256.Sum256([]byte(data))
+	var signature []byte
+	var err error
+	if algorithm == SignatureAlgorithmSHA256WithRSAPSS {
+		signature, err = rsa.SignPSS(rand.Reader, key, crypto.SHA256, hashed[:], nil)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func marshalTestNotification(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+const testStatusUpdate = `{"environment":"PROD","notificationType":2,"subscriptionId":"sub-1","purchaseToken":"token-1","applicationId":"app-1","productId":"premium"}`
+
+func TestVerifySignatureWithAlgorithm(t *testing.T) {
+	t.Parallel()
+	key, publicKey := newTestNotificationKey(t)
+	_, otherKey := newTestNotificationKey(t)
+
+	for _, algorithm := range []string{"", SignatureAlgorithmSHA256WithRSA, SignatureAlgorithmSHA256WithRSAPSS} {
+		signature := signTestNotification(t, key, testStatusUpdate, algorithm)
+		if err := VerifySignatureWithAlgorithm(publicKey, testStatusUpdate, signature, algorithm); err != nil {
+			t.Errorf("%q: %v", algorithm, err)
+		}
+		if err := VerifySignatureWithAlgorithm(otherKey, testStatusUpdate, signature, algorithm); err == nil {
+			t.Errorf("%q: got nil, want an error for another key", algorithm)
+		}
+		if err := VerifySignatureWithAlgorithm(publicKey, testStatusUpdate+" ", signature, algorithm); err == nil {
+			t.Errorf("%q: got nil, want an error for tampered data", algorithm)
+		}
+	}
+
+	// a PKCS1v15 signature is not a valid PSS signature
+	signature := signTestNotification(t, key, testStatusUpdate, SignatureAlgorithmSHA256WithRSA)
+	if err := VerifySignatureWithAlgorithm(publicKey, testStatusUpdate, signature, SignatureAlgorithmSHA256WithRSAPSS); err == nil {
+		t.Error("got nil, want an error for a signature of another algorithm")
+	}
+	if err := VerifySignatureWithAlgorithm(publicKey, testStatusUpdate, signature, "SHA1WithRSA"); !errors.Is(err, ErrUnsupportedSignatureAlgorithm) {
+		t.Errorf("got %v, want %v", err, ErrUnsupportedSignatureAlgorithm)
+	}
+}
+
+func TestParseNotification(t *testing.T) {
+	t.Parallel()
+	key, publicKey := newTestNotificationKey(t)
+
+	t.Run("v1", func(t *testing.T) {
+		body := marshalTestNotification(t, SubscriptionNotification{
+			StatusUpdateNotification: testStatusUpdate,
+			NotifycationSignature:    signTestNotification(t, key, testStatusUpdate, ""),
+		})
+		got, err := ParseNotification(body, publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.EventType != EventTypeSubscription || got.Order != nil || got.Subscription == nil {
+			t.Fatalf("unexpected notification %+v", got)
+		}
+		if got.Subscription.Type != SubscriptionNotificationTypeRenewal || got.Subscription.SubscriptionID != "sub-1" || got.Subscription.PurchaseToken != "token-1" {
+			t.Errorf("unexpected subscription %+v", got.Subscription)
+		}
+		if got.Subscription.Type.String() != "RENEWAL" {
+			t.Errorf("got %s, want RENEWAL", got.Subscription.Type)
+		}
+	})
+
+	t.Run("v2 subscription", func(t *testing.T) {
+		body := marshalTestNotification(t, SubscriptionNotificationV2{
+			Version:       "v2",
+			EventType:     EventTypeSubscription,
+			NotifyTime:    1700000000000,
+			ApplicationID: "app-1",
+			SubNotification: SubNotification{
+				StatusUpdateNotification: testStatusUpdate,
+				NotificationSignature:    signTestNotification(t, key, testStatusUpdate, SignatureAlgorithmSHA256WithRSAPSS),
+				SignatureAlgorithm:       SignatureAlgorithmSHA256WithRSAPSS,
+			},
+		})
+		got, err := ParseNotification(body, publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != "v2" || got.NotifyTime != 1700000000000 || got.ApplicationID != "app-1" || got.Subscription == nil {
+			t.Fatalf("unexpected notification %+v", got)
+		}
+		if got.Subscription.Type != SubscriptionNotificationTypeRenewal {
+			t.Errorf("got %v, want %v", got.Subscription.Type, SubscriptionNotificationTypeRenewal)
+		}
+	})
+
+	t.Run("v2 order", func(t *testing.T) {
+		body := []byte(`{"version":"v2","eventType":"ORDER","notifyTime":1700000000000,"applicationId":"app-1",` +
+			`"orderNotification":{"version":"v2","notificationType":2,"purchaseToken":"token-2","productId":"coins"}}`)
+		got, err := ParseNotification(body, publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.EventType != EventTypeOrder || got.Subscription != nil || got.Order == nil {
+			t.Fatalf("unexpected notification %+v", got)
+		}
+		if got.Order.Type != OrderNotificationTypeRefundSuccess || got.Order.PurchaseToken != "token-2" || got.Order.ProductID != "coins" {
+			t.Errorf("unexpected order %+v", got.Order)
+		}
+	})
+
+	tampered := `{"environment":"PROD","notificationType":0,"subscriptionId":"sub-1","purchaseToken":"token-1"}`
+	errorTests := []struct {
+		name string
+		body []byte
+	}{
+		{name: "tampered v1", body: marshalTestNotification(t, SubscriptionNotification{
+			StatusUpdateNotification: tampered,
+			NotifycationSignature:    signTestNotification(t, key, testStatusUpdate, ""),
+		})},
+		{name: "tampered v2", body: marshalTestNotification(t, SubscriptionNotificationV2{
+			EventType: EventTypeSubscription,
+			SubNotification: SubNotification{
+				StatusUpdateNotification: tampered,
+				NotificationSignature:    signTestNotification(t, key, testStatusUpdate, SignatureAlgorithmSHA256WithRSAPSS),
+				SignatureAlgorithm:       SignatureAlgorithmSHA256WithRSAPSS,
+			},
+		})},
+		{name: "invalid signature", body: marshalTestNotification(t, SubscriptionNotification{
+			StatusUpdateNotification: testStatusUpdate,
+			NotifycationSignature:    base64.StdEncoding.EncodeToString([]byte("signature")),
+		})},
+		{name: "unknown event", body: []byte(`{"version":"v2","eventType":"UNKNOWN"}`)},
+		{name: "malformed", body: []byte(`{`)},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ParseNotification(tt.body, publicKey); err == nil {
+				t.Errorf("got %+v, want an error", got)
+			}
+		})
+	}
+
+	if _, err := ParseNotification([]byte(`{"version":"v2","eventType":"UNKNOWN"}`), publicKey); !errors.Is(err, ErrInvalidNotification) {
+		t.Errorf("got %v, want %v", err, ErrInvalidNotification)
+	}
+}
+
+func TestSubscriptionNotificationType_String(t *testing.T) {
+	t.Parallel()
+	if got := SubscriptionNotificationTypeDeferred.String(); got != "DEFERRED" {
+		t.Errorf("got %s, want DEFERRED", got)
+	}
+	if got := SubscriptionNotificationType(99).String(); got != "SubscriptionNotificationType(99)" {
+		t.Errorf("got %s, want SubscriptionNotificationType(99)", got)
+	}
+}
@@ -3,11 +3,6 @@ package hms
 import (
 	"bytes"
 	"context"
-	"crypto"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,25 +10,12 @@ import (
 	"time"
 )
 
-// VerifySignature validate inapp order or subscription data signature. Returns nil if pass.
+// VerifySignature validate inapp order or subscription data signature with SHA256WithRSA. Returns nil if pass.
 //
 // Document: https://developer.huawei.com/consumer/en/doc/development/HMSCore-Guides/verifying-signature-returned-result-0000001050033088
 // Source code originated from https://github.com/HMS-Core/hms-iap-serverdemo/blob/92241f97fed1b68ddeb7cb37ea4ca6e6d33d2a87/demo/demo.go#L60
 func VerifySignature(base64EncodedPublicKey string, data string, signature string) (err error) {
-	publicKeyByte, err := base64.StdEncoding.DecodeString(base64EncodedPublicKey)
-	if err != nil {
-		return err
-	}
-	pub, err := x509.ParsePKIXPublicKey(publicKeyByte)
-	if err != nil {
-		return err
-	}
-	hashed := sha256.Sum256([]byte(data))
-	signatureByte, err := base64.StdEncoding.DecodeString(signature)
-	if err != nil {
-		return err
-	}
-	return rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, hashed[:], signatureByte)
+	return VerifySignatureWithAlgorithm(base64EncodedPublicKey, data, signature, SignatureAlgorithmSHA256WithRSA)
 }
 
 // SubscriptionVerifyResponse JSON response after requested {rootUrl}/sub/applications/v2/purchases/get
@@ -0,0 +1,73 @@
+package microsoftstore
+
+import (
+	"context"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+// Entitlement converts the collection item into a normalized entitlement.
+// ProductID is the Store ID of the product, the developer-specified InAppOfferToken is not used.
+// The collection does not contain the price, so PriceMicros and Currency are left empty.
+func (c CollectionItemContractV6) Entitlement() entitlement.Entitlement {
+	e := entitlement.Entitlement{
+		Store:         entitlement.MicrosoftStore,
+		ProductID:     c.ProductId,
+		OriginalID:    c.ItemId,
+		TransactionID: c.TransactionId,
+		PeriodStart:   c.StartDate,
+		PeriodEnd:     c.EndDate,
+		Trial:         c.SkuType == "Trial",
+	}
+	switch c.Status {
+	case "Active":
+		e.State = entitlement.StateActive
+	case "Revoked", "Banned":
+		e.State = entitlement.StateRevoked
+		e.Revoked = true
+		e.RevokedAt = c.ModifiedDate
+	default:
+		e.State = entitlement.StateExpired
+	}
+	return e
+}
+
+// Entitlements converts every item of the response into a normalized entitlement.
+func (r IAPResponse) Entitlements() []entitlement.Entitlement {
+	entitlements := make([]entitlement.Entitlement, 0, len(r.Items))
+	for _, item := range r.Items {
+		entitlements = append(entitlements, item.Entitlement())
+	}
+	return entitlements
+}
+
+// EntitlementVerifier queries the collection of a user as an entitlement.Verifier.
+type EntitlementVerifier struct {
+	client IAPClient
+}
+
+// Verify that EntitlementVerifier implements entitlement.Verifier
+var _ entitlement.Verifier = (*EntitlementVerifier)(nil)
+
+// NewEntitlementVerifier creates a verifier from the client.
+func NewEntitlementVerifier(client IAPClient) *EntitlementVerifier {
+	return &EntitlementVerifier{client: client}
+}
+
+// Verify queries the products owned by the user whose Microsoft Store ID key is purchase.UserID.
+// purchase.Token is sent as the localTicketReference, which the collection items return.
+// When purchase.ProductID is set, only that product is queried. The result contains IAPResponse as Raw.
+func (v *EntitlementVerifier) Verify(ctx context.Context, purchase entitlement.Purchase) (*entitlement.Result, error) {
+	req := IAPRequest{
+		Beneficiaries: []UserIdentity{{IdentityType: "b2b", IdentityValue: purchase.UserID, LocalTicketReference: purchase.Token}},
+		ProductTypes:  []ProductType{Application, Durable, Game, UnmanagedConsumable},
+	}
+	if purchase.ProductID != "" {
+		req.ProductSkuIds = []ProductSkuId{{ProductId: purchase.ProductID}}
+	}
+	resp, err := v.client.Verify(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &entitlement.Result{Entitlements: resp.Entitlements(), Raw: resp}, nil
+}
@@ -17,7 +17,7 @@ const (
 
 // IAPClient is an interface to call validation API in Microsoft Store
 type IAPClient interface {
-	Verify(context.Context, string, string) (IAPResponse, error)
+	Verify(context.Context, IAPRequest) (IAPResponse, error)
 }
 
 // Client implements IAPClient
@@ -28,6 +28,9 @@ type Client struct {
 	httpCli      *http.Client
 }
 
+// Verify that Client implements IAPClient
+var _ IAPClient = (*Client)(nil)
+
 // New creates a client object
 func New(tenantId, clientId, secret string) *Client {
 	client := &Client{
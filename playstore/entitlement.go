@@ -0,0 +1,156 @@
+package playstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+// ProductPurchaseState is the purchaseState of a ProductPurchase.
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.products#ProductPurchase
+type ProductPurchaseState int64
+
+const (
+	ProductPurchaseStatePurchased ProductPurchaseState = 0
+	ProductPurchaseStateCanceled  ProductPurchaseState = 1
+	ProductPurchaseStatePending   ProductPurchaseState = 2
+)
+
+// SubscriptionEntitlements converts a subscription purchase into normalized entitlements as of now, see SubscriptionEntitlementsAt.
+func SubscriptionEntitlements(purchase *androidpublisher.SubscriptionPurchaseV2) []entitlement.Entitlement {
+	return SubscriptionEntitlementsAt(purchase, time.Now())
+}
+
+// SubscriptionEntitlementsAt converts a subscription purchase into normalized entitlements at now, one for each line item.
+// The states are the ones of EvaluateSubscription, so a line item past its expiry time is expired.
+// The API does not tell whether the user is in a free trial, so Trial is never set and IntroOffer
+// is set when the line item was bought with an offer rather than the base plan.
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.subscriptionsv2
+func SubscriptionEntitlementsAt(purchase *androidpublisher.SubscriptionPurchaseV2, now time.Time) []entitlement.Entitlement {
+	status := EvaluateSubscription(purchase, now)
+	start := parseRFC3339(purchase.StartTime)
+
+	entitlements := make([]entitlement.Entitlement, 0, len(purchase.LineItems))
+	for i, item := range purchase.LineItems {
+		e := entitlement.Entitlement{
+			Store:         entitlement.PlayStore,
+			ProductID:     item.ProductId,
+			OriginalID:    originalOrderID(purchase.LatestOrderId),
+			TransactionID: purchase.LatestOrderId,
+			State:         status.LineItems[i].State,
+			AutoRenew:     status.LineItems[i].AutoRenew,
+			PeriodStart:   start,
+			PeriodEnd:     status.LineItems[i].ExpiryTime,
+		}
+		if item.LatestSuccessfulOrderId != "" {
+			e.TransactionID = item.LatestSuccessfulOrderId
+			e.OriginalID = originalOrderID(item.LatestSuccessfulOrderId)
+		}
+		if plan := item.AutoRenewingPlan; plan != nil && plan.RecurringPrice != nil {
+			e.PriceMicros = plan.RecurringPrice.Units*1e6 + plan.RecurringPrice.Nanos/1e3
+			e.Currency = plan.RecurringPrice.CurrencyCode
+		}
+		if item.OfferDetails != nil && item.OfferDetails.OfferId != "" {
+			e.IntroOffer = true
+		}
+		entitlements = append(entitlements, e)
+	}
+	return entitlements
+}
+
+// ProductEntitlement converts a one-time product purchase into a normalized entitlement.
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.products
+func ProductEntitlement(productID string, purchase *androidpublisher.ProductPurchase) entitlement.Entitlement {
+	e := entitlement.Entitlement{
+		Store:         entitlement.PlayStore,
+		ProductID:     productID,
+		OriginalID:    purchase.OrderId,
+		TransactionID: purchase.OrderId,
+		PeriodStart:   entitlement.FromMillis(purchase.PurchaseTimeMillis),
+	}
+	if purchase.ProductId != "" {
+		e.ProductID = purchase.ProductId
+	}
+	switch ProductPurchaseState(purchase.PurchaseState) {
+	case ProductPurchaseStatePurchased:
+		e.State = entitlement.StateActive
+	case ProductPurchaseStateCanceled:
+		e.State = entitlement.StateRevoked
+		e.Revoked = true
+	default:
+		e.State = entitlement.StatePending
+	}
+	return e
+}
+
+func subscriptionStateToEntitlement(state string) entitlement.State {
+	switch state {
+	case SubscriptionStateActive, SubscriptionStateCanceled:
+		// a canceled subscription keeps access until it expires
+		return entitlement.StateActive
+	case SubscriptionStateInGracePeriod:
+		return entitlement.StateGracePeriod
+	case SubscriptionStateOnHold:
+		return entitlement.StateBillingRetry
+	case SubscriptionStatePaused:
+		return entitlement.StatePaused
+	case SubscriptionStatePending:
+		return entitlement.StatePending
+	default:
+		return entitlement.StateExpired
+	}
+}
+
+// originalOrderID strips the renewal suffix, e.g. GPA.1234-5678-9012-34567..1 to GPA.1234-5678-9012-34567.
+func originalOrderID(orderID string) string {
+	if i := strings.Index(orderID, ".."); i >= 0 {
+		return orderID[:i]
+	}
+	return orderID
+}
+
+func parseRFC3339(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// EntitlementVerifier verifies Google Play purchase tokens as an entitlement.Verifier.
+type EntitlementVerifier struct {
+	client interface {
+		IABProduct
+		IABSubscriptionV2
+	}
+}
+
+// Verify that EntitlementVerifier implements entitlement.Verifier
+var _ entitlement.Verifier = (*EntitlementVerifier)(nil)
+
+// NewEntitlementVerifier creates a verifier from the client, it is usually *Client.
+func NewEntitlementVerifier(client interface {
+	IABProduct
+	IABSubscriptionV2
+}) *EntitlementVerifier {
+	return &EntitlementVerifier{client: client}
+}
+
+// Verify gets the purchase of purchase.Token with the subscriptionsv2 API when purchase.Subscription is true,
+// with the products API otherwise. The result contains the androidpublisher response as Raw.
+func (v *EntitlementVerifier) Verify(ctx context.Context, purchase entitlement.Purchase) (*entitlement.Result, error) {
+	if purchase.Subscription {
+		resp, err := v.client.VerifySubscriptionV2(ctx, purchase.PackageName, purchase.Token)
+		if err != nil {
+			return nil, err
+		}
+		return &entitlement.Result{Entitlements: SubscriptionEntitlements(resp), Raw: resp}, nil
+	}
+
+	resp, err := v.client.VerifyProduct(ctx, purchase.PackageName, purchase.ProductID, purchase.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &entitlement.Result{Entitlements: []entitlement.Entitlement{ProductEntitlement(purchase.ProductID, resp)}, Raw: resp}, nil
+}
@@ -0,0 +1,74 @@
+package playstore
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+func TestSubscriptionEntitlements(t *testing.T) {
+	t.Parallel()
+	purchase := &androidpublisher.SubscriptionPurchaseV2{
+		SubscriptionState: "SUBSCRIPTION_STATE_CANCELED",
+		StartTime:         "2023-01-01T00:00:00.000Z",
+		LatestOrderId:     "GPA.1234-5678-9012-34567..2",
+		LineItems: []*androidpublisher.SubscriptionPurchaseLineItem{
+			{
+				ProductId:  "monthly",
+				ExpiryTime: "2023-03-01T00:00:00.000Z",
+				AutoRenewingPlan: &androidpublisher.AutoRenewingPlan{
+					AutoRenewEnabled: false,
+					RecurringPrice:   &androidpublisher.Money{CurrencyCode: "JPY", Units: 480, Nanos: 500000000},
+				},
+				OfferDetails: &androidpublisher.OfferDetails{BasePlanId: "p1m", OfferId: "intro"},
+			},
+		},
+	}
+
+	got := SubscriptionEntitlementsAt(purchase, time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC))
+	if len(got) != 1 {
+		t.Fatalf("got %d entitlements, want 1", len(got))
+	}
+	expected := entitlement.Entitlement{
+		Store:         entitlement.PlayStore,
+		ProductID:     "monthly",
+		OriginalID:    "GPA.1234-5678-9012-34567",
+		TransactionID: "GPA.1234-5678-9012-34567..2",
+		State:         entitlement.StateActive,
+		PeriodStart:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:     time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+		IntroOffer:    true,
+		PriceMicros:   480500000,
+		Currency:      "JPY",
+	}
+	if got[0] != expected {
+		t.Errorf("got %+v\nwant %+v", got[0], expected)
+	}
+
+	// the canceled subscription no longer grants access after its expiry time
+	got = SubscriptionEntitlementsAt(purchase, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC))
+	if got[0].State != entitlement.StateExpired {
+		t.Errorf("got %v, want %v", got[0].State, entitlement.StateExpired)
+	}
+}
+
+func TestSubscriptionStateToEntitlement(t *testing.T) {
+	t.Parallel()
+	tests := map[string]entitlement.State{
+		"SUBSCRIPTION_STATE_ACTIVE":                    entitlement.StateActive,
+		"SUBSCRIPTION_STATE_IN_GRACE_PERIOD":           entitlement.StateGracePeriod,
+		"SUBSCRIPTION_STATE_ON_HOLD":                   entitlement.StateBillingRetry,
+		"SUBSCRIPTION_STATE_PAUSED":                    entitlement.StatePaused,
+		"SUBSCRIPTION_STATE_PENDING":                   entitlement.StatePending,
+		"SUBSCRIPTION_STATE_EXPIRED":                   entitlement.StateExpired,
+		"SUBSCRIPTION_STATE_PENDING_PURCHASE_CANCELED": entitlement.StateExpired,
+	}
+	for state, expected := range tests {
+		if got := subscriptionStateToEntitlement(state); got != expected {
+			t.Errorf("%s: got %v, want %v", state, got, expected)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package playtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+)
+
+const (
+	basePath = "/androidpublisher/v3/applications/{packageName}"
+
+	// defaultVoidedMaxResults is the page size of the voided purchases when maxResults is not set.
+	defaultVoidedMaxResults = 1000
+	// voidedLookback is how far back the voided purchases are listed when startTime is not set.
+	voidedLookback = 30 * 24 * time.Hour
+)
+
+// errorResponse is the error body of Google APIs, which googleapi.CheckResponse decodes into *googleapi.Error.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Status  string        `json:"status"`
+	Errors  []errorDetail `json:"errors"`
+}
+
+type errorDetail struct {
+	Message string `json:"message"`
+	Domain  string `json:"domain"`
+	Reason  string `json:"reason"`
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+basePath+"/purchases/subscriptionsv2/tokens/{token}", s.getSubscriptionV2)
+	mux.HandleFunc("GET "+basePath+"/purchases/products/{productId}/tokens/{token}", s.getProduct)
+	// ServeMux wildcards must be whole segments, so ":acknowledge" and ":consume" are split from the token.
+	mux.HandleFunc("POST "+basePath+"/purchases/products/{productId}/tokens/{token}", s.productAction)
+	mux.HandleFunc("GET "+basePath+"/purchases/voidedpurchases", s.listVoidedPurchases)
+	mux.HandleFunc("GET "+basePath+"/orders/{orderId}", s.getOrder)
+	mux.HandleFunc("GET "+basePath+"/orders:batchGet", s.batchGetOrders)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, reason, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: errorBody{
+		Code:    code,
+		Message: message,
+		Status:  strings.ToUpper(strings.ReplaceAll(http.StatusText(code), " ", "_")),
+		Errors:  []errorDetail{{Message: message, Domain: "androidpublisher", Reason: reason}},
+	}})
+}
+
+func writeNotFound(w http.ResponseWriter, what string) {
+	writeError(w, http.StatusNotFound, "purchaseTokenNotFound", what+" not found.")
+}
+
+// getSubscriptionV2 https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.subscriptionsv2/get
+func (s *Server) getSubscriptionV2(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purchase, ok := s.subscriptions[key(r.PathValue("packageName"), r.PathValue("token"))]
+	if !ok {
+		writeNotFound(w, "The subscription purchase")
+		return
+	}
+	writeJSON(w, purchase)
+}
+
+// getProduct https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.products/get
+func (s *Server) getProduct(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purchase, ok := s.products[key(r.PathValue("packageName"), r.PathValue("productId"), r.PathValue("token"))]
+	if !ok {
+		writeNotFound(w, "The product purchase")
+		return
+	}
+	writeJSON(w, purchase)
+}
+
+// productAction serves acknowledge and consume.
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.products/acknowledge
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.products/consume
+func (s *Server) productAction(w http.ResponseWriter, r *http.Request) {
+	token, action, ok := strings.Cut(r.PathValue("token"), ":")
+	if !ok || (action != "acknowledge" && action != "consume") {
+		http.NotFound(w, r)
+		return
+	}
+	var req androidpublisher.ProductPurchasesAcknowledgeRequest
+	if action == "acknowledge" && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("Invalid JSON payload received. %v", err))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purchase, ok := s.products[key(r.PathValue("packageName"), r.PathValue("productId"), token)]
+	if !ok {
+		writeNotFound(w, "The product purchase")
+		return
+	}
+	if purchase.PurchaseState != 0 {
+		writeError(w, http.StatusBadRequest, "purchaseNotPurchased", "The purchase is not in the purchased state.")
+		return
+	}
+	switch action {
+	case "acknowledge":
+		purchase.AcknowledgementState = 1
+		purchase.DeveloperPayload = req.DeveloperPayload
+	case "consume":
+		purchase.ConsumptionState = 1
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listVoidedPurchases https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.voidedpurchases/list
+func (s *Server) listVoidedPurchases(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := s.now()
+	startTime, ok := int64Param(query.Get("startTime"), now.Add(-voidedLookback).UnixMilli())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid", "Invalid startTime.")
+		return
+	}
+	endTime, ok := int64Param(query.Get("endTime"), now.UnixMilli())
+	if !ok || endTime < startTime {
+		writeError(w, http.StatusBadRequest, "invalid", "Invalid endTime.")
+		return
+	}
+	maxResults, ok := int64Param(query.Get("maxResults"), defaultVoidedMaxResults)
+	if !ok || maxResults <= 0 || maxResults > defaultVoidedMaxResults {
+		writeError(w, http.StatusBadRequest, "invalid", "Invalid maxResults.")
+		return
+	}
+	voidedType, ok := int64Param(query.Get("type"), 0)
+	if !ok || (voidedType != 0 && voidedType != 1) {
+		writeError(w, http.StatusBadRequest, "invalid", "Invalid type.")
+		return
+	}
+	start, ok := int64Param(query.Get("startIndex"), 0)
+	if !ok || start < 0 {
+		writeError(w, http.StatusBadRequest, "invalid", "Invalid startIndex.")
+		return
+	}
+	if token := query.Get("token"); token != "" {
+		if start, ok = int64Param(token, 0); !ok || start < 0 {
+			writeError(w, http.StatusBadRequest, "invalid", "Invalid token.")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	packageName := r.PathValue("packageName")
+	var matched []*androidpublisher.VoidedPurchase
+	for _, purchase := range s.voided[packageName] {
+		_, subscription := s.subscriptions[key(packageName, purchase.PurchaseToken)]
+		if purchase.VoidedTimeMillis < startTime || purchase.VoidedTimeMillis > endTime || (subscription && voidedType == 0) {
+			continue
+		}
+		matched = append(matched, purchase)
+	}
+
+	if start > int64(len(matched)) {
+		start = int64(len(matched))
+	}
+	end := start + maxResults
+	if end > int64(len(matched)) {
+		end = int64(len(matched))
+	}
+	rsp := &androidpublisher.VoidedPurchasesListResponse{
+		PageInfo: &androidpublisher.PageInfo{
+			ResultPerPage: end - start,
+			StartIndex:    start,
+			TotalResults:  int64(len(matched)),
+		},
+		VoidedPurchases: matched[start:end],
+	}
+	if end < int64(len(matched)) {
+		rsp.TokenPagination = &androidpublisher.TokenPagination{NextPageToken: strconv.FormatInt(end, 10)}
+	}
+	writeJSON(w, rsp)
+}
+
+func int64Param(v string, defaultValue int64) (int64, bool) {
+	if v == "" {
+		return defaultValue, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	return n, err == nil
+}
+
+// getOrder https://developers.google.com/android-publisher/api-ref/rest/v3/orders/get
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[key(r.PathValue("packageName"), r.PathValue("orderId"))]
+	if !ok {
+		writeError(w, http.StatusNotFound, "orderNotFound", "The order was not found.")
+		return
+	}
+	writeJSON(w, order)
+}
+
+// batchGetOrders https://developers.google.com/android-publisher/api-ref/rest/v3/orders/batchget
+// Unknown order IDs are left out of the response.
+func (s *Server) batchGetOrders(w http.ResponseWriter, r *http.Request) {
+	orderIDs := r.URL.Query()["orderIds"]
+	if len(orderIDs) == 0 || len(orderIDs) > 1000 {
+		writeError(w, http.StatusBadRequest, "invalid", "Between 1 and 1000 orderIds must be given.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rsp := &androidpublisher.BatchGetOrdersResponse{}
+	for _, id := range orderIDs {
+		if order, ok := s.orders[key(r.PathValue("packageName"), id)]; ok {
+			rsp.Orders = append(rsp.Orders, order)
+		}
+	}
+	writeJSON(w, rsp)
+}
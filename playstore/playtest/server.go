@@ -0,0 +1,109 @@
+// Package playtest runs an in-process fake of the Google Play Developer API for tests.
+// It serves the androidpublisher endpoints used by playstore.Client from an in-memory store,
+// so request encoding, pagination and error mapping are exercised without network.
+package playtest
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+	"google.golang.org/api/option"
+
+	"github.com/awa/go-iap/playstore"
+)
+
+// Server is a fake Google Play Developer API. Now must be set before the first request.
+type Server struct {
+	// URL is the base URL of the server, use it with option.WithEndpoint.
+	URL string
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+
+	srv *httptest.Server
+
+	mu            sync.Mutex
+	subscriptions map[string]*androidpublisher.SubscriptionPurchaseV2
+	products      map[string]*androidpublisher.ProductPurchase
+	voided        map[string][]*androidpublisher.VoidedPurchase
+	orders        map[string]*androidpublisher.Order
+}
+
+// NewServer starts a fake Google Play Developer API. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		subscriptions: map[string]*androidpublisher.SubscriptionPurchaseV2{},
+		products:      map[string]*androidpublisher.ProductPurchase{},
+		voided:        map[string][]*androidpublisher.VoidedPurchase{},
+		orders:        map[string]*androidpublisher.Order{},
+	}
+	s.srv = httptest.NewServer(s.routes())
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a playstore.Client which sends its requests to the server without credentials.
+func (s *Server) Client() (*playstore.Client, error) {
+	return playstore.NewWithOptions(
+		option.WithEndpoint(s.URL+"/"),
+		option.WithHTTPClient(s.srv.Client()),
+	)
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func key(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// AddSubscription stores the subscription purchase of the purchase token.
+func (s *Server) AddSubscription(packageName, token string, purchase *androidpublisher.SubscriptionPurchaseV2) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[key(packageName, token)] = purchase
+}
+
+// AddProduct stores the product purchase of the purchase token.
+func (s *Server) AddProduct(packageName, productID, token string, purchase *androidpublisher.ProductPurchase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products[key(packageName, productID, token)] = purchase
+}
+
+// Product returns a copy of the stored product purchase, including the acknowledgement and consumption made through the API.
+func (s *Server) Product(packageName, productID, token string) (androidpublisher.ProductPurchase, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purchase, ok := s.products[key(packageName, productID, token)]
+	if !ok {
+		return androidpublisher.ProductPurchase{}, false
+	}
+	return *purchase, true
+}
+
+// AddVoidedPurchase stores a voided purchase. It is listed as a subscription when its purchase token
+// was added with AddSubscription.
+func (s *Server) AddVoidedPurchase(packageName string, purchase *androidpublisher.VoidedPurchase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.voided[packageName] = append(s.voided[packageName], purchase)
+}
+
+// AddOrder stores an order.
+func (s *Server) AddOrder(packageName string, order *androidpublisher.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[key(packageName, order.OrderId)] = order
+}
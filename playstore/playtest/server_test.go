@@ -0,0 +1,152 @@
+package playtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+	"google.golang.org/api/googleapi"
+
+	"github.com/awa/go-iap/playstore"
+)
+
+const packageName = "com.example.app"
+
+func newTestClient(t *testing.T) (*Server, *playstore.Client) {
+	t.Helper()
+	s := NewServer()
+	t.Cleanup(s.Close)
+	client, err := s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, client
+}
+
+func TestServer_Subscription(t *testing.T) {
+	t.Parallel()
+	s, client := newTestClient(t)
+	s.AddSubscription(packageName, "sub-token", &androidpublisher.SubscriptionPurchaseV2{
+		LatestOrderId:     "GPA.0000-0000-0000-00001",
+		SubscriptionState: "SUBSCRIPTION_STATE_ACTIVE",
+		LineItems:         []*androidpublisher.SubscriptionPurchaseLineItem{{ProductId: "monthly", ExpiryTime: "2030-01-01T00:00:00Z"}},
+	})
+	ctx := context.Background()
+
+	got, err := client.VerifySubscriptionV2(ctx, packageName, "sub-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SubscriptionState != "SUBSCRIPTION_STATE_ACTIVE" || got.LineItems[0].ProductId != "monthly" {
+		t.Errorf("unexpected subscription %+v", got)
+	}
+
+	_, err = client.VerifySubscriptionV2(ctx, packageName, "unknown")
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Errorf("got %v, want a 404 googleapi.Error", err)
+	}
+}
+
+func TestServer_Product(t *testing.T) {
+	t.Parallel()
+	s, client := newTestClient(t)
+	s.AddProduct(packageName, "coins", "product-token", &androidpublisher.ProductPurchase{OrderId: "GPA.0000-0000-0000-00002"})
+	ctx := context.Background()
+
+	if err := client.AcknowledgeProduct(ctx, packageName, "coins", "product-token", "payload"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ConsumeProduct(ctx, packageName, "coins", "product-token"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.VerifyProduct(ctx, packageName, "coins", "product-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AcknowledgementState != 1 || got.ConsumptionState != 1 || got.DeveloperPayload != "payload" {
+		t.Errorf("unexpected product %+v", got)
+	}
+	if stored, _ := s.Product(packageName, "coins", "product-token"); stored.ConsumptionState != 1 {
+		t.Errorf("unexpected stored product %+v", stored)
+	}
+
+	err = client.ConsumeProduct(ctx, packageName, "coins", "unknown")
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Errorf("got %v, want a 404 googleapi.Error", err)
+	}
+}
+
+func TestServer_VoidedPurchases(t *testing.T) {
+	t.Parallel()
+	s, client := newTestClient(t)
+	now := time.Now()
+	s.AddSubscription(packageName, "sub-token", &androidpublisher.SubscriptionPurchaseV2{})
+	for _, token := range []string{"a", "b", "c", "sub-token"} {
+		s.AddVoidedPurchase(packageName, &androidpublisher.VoidedPurchase{PurchaseToken: token, VoidedTimeMillis: now.Add(-time.Hour).UnixMilli()})
+	}
+	s.AddVoidedPurchase(packageName, &androidpublisher.VoidedPurchase{PurchaseToken: "old", VoidedTimeMillis: now.AddDate(0, -2, 0).UnixMilli()})
+	ctx := context.Background()
+	startTime, endTime := now.AddDate(0, 0, -7).UnixMilli(), now.UnixMilli()
+
+	var tokens []string
+	pageToken := ""
+	for {
+		rsp, err := client.VoidedPurchases(ctx, packageName, startTime, endTime, 2, pageToken, 0, playstore.VoidedPurchaseTypeWithoutSubscription)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range rsp.VoidedPurchases {
+			tokens = append(tokens, v.PurchaseToken)
+		}
+		if rsp.TokenPagination == nil {
+			break
+		}
+		pageToken = rsp.TokenPagination.NextPageToken
+	}
+	if len(tokens) != 3 {
+		t.Errorf("got %v, want [a b c]", tokens)
+	}
+
+	rsp, err := client.VoidedPurchases(ctx, packageName, startTime, endTime, 10, "", 0, playstore.VoidedPurchaseTypeWithSubscription)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.VoidedPurchases) != 4 {
+		t.Errorf("got %d voided purchases, want 4", len(rsp.VoidedPurchases))
+	}
+}
+
+func TestServer_Orders(t *testing.T) {
+	t.Parallel()
+	s, client := newTestClient(t)
+	s.AddOrder(packageName, &androidpublisher.Order{OrderId: "GPA.1", State: "PROCESSED"})
+	s.AddOrder(packageName, &androidpublisher.Order{OrderId: "GPA.2", State: "REFUNDED"})
+	ctx := context.Background()
+
+	order, err := client.GetOrder(ctx, packageName, "GPA.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.State != "PROCESSED" {
+		t.Errorf("got %v, want PROCESSED", order.State)
+	}
+
+	orders, err := client.BatchGetOrder(ctx, packageName, "GPA.1", "GPA.2", "GPA.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders.Orders) != 2 {
+		t.Errorf("got %d orders, want 2", len(orders.Orders))
+	}
+
+	_, err = client.GetOrder(ctx, packageName, "GPA.3")
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Errorf("got %v, want a 404 googleapi.Error", err)
+	}
+}
@@ -0,0 +1,305 @@
+package playstore
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// GoogleCertsURL is the JWKS of the keys Google signs the Pub/Sub push tokens with.
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// list of errors returned while authenticating a push request
+var (
+	ErrPushTokenMissing    = errors.New("playstore: push request has no bearer token")
+	ErrPushTokenInvalid    = errors.New("playstore: push token is invalid")
+	ErrUnknownKeyID        = errors.New("playstore: key id of the push token is unknown")
+	ErrPushAudienceMissing = errors.New("playstore: audience of the push subscription is required")
+	ErrPushMessageInvalid  = errors.New("playstore: push message is malformed")
+	ErrPushHandleMissing   = errors.New("playstore: handle of the notification handler is required")
+)
+
+// PubSubMessage is a Pub/Sub message. Data is decoded from base64 when the push request is unmarshaled.
+// https://cloud.google.com/pubsub/docs/reference/rest/v1/PubsubMessage
+type PubSubMessage struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId"`
+	PublishTime string            `json:"publishTime"`
+}
+
+// PushRequest is the body of a Pub/Sub push request.
+// https://cloud.google.com/pubsub/docs/push#receive_push
+type PushRequest struct {
+	Message      PubSubMessage `json:"message"`
+	Subscription string        `json:"subscription"`
+}
+
+// KeySet resolves the public key a push token is signed with from its key id.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeySet is a KeySet of fixed keys, keyed by key id.
+type StaticKeySet map[string]crypto.PublicKey
+
+// Key implements KeySet.
+func (s StaticKeySet) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKeyID
+}
+
+// JWKS is a KeySet fetched from a JSON Web Key Set URL. The keys are cached as long as the Cache-Control header allows,
+// an unknown key id triggers a refresh at most once a minute.
+type JWKS struct {
+	URL    string
+	Client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+// NewJWKS returns a JWKS of url, e.g. GoogleCertsURL.
+func NewJWKS(url string) *JWKS {
+	return &JWKS{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Key implements KeySet.
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	key, ok := j.keys[kid]
+	if ok && now.Before(j.expiresAt) {
+		return key, nil
+	}
+	if !ok && now.Before(j.expiresAt) && now.Sub(j.fetchedAt) < time.Minute {
+		return nil, ErrUnknownKeyID
+	}
+	if err := j.refresh(ctx, now); err != nil {
+		return nil, err
+	}
+	if key, ok = j.keys[kid]; !ok {
+		return nil, ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+func (j *JWKS) refresh(ctx context.Context, now time.Time) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return err
+	}
+	client := j.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("playstore: failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("playstore: failed to fetch JWKS: status code %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return fmt.Errorf("playstore: failed to decode JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("playstore: invalid JWKS modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("playstore: invalid JWKS exponent: %w", err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	j.keys = keys
+	j.fetchedAt = now
+	j.expiresAt = now.Add(maxAge(resp.Header.Get("Cache-Control")))
+	return nil
+}
+
+// maxAge returns the max-age of a Cache-Control header, an hour when it has none.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+			if seconds, err := strconv.Atoi(v); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return time.Hour
+}
+
+// NotificationHandlerFunc handles a decoded notification. Returning nil acknowledges the message,
+// returning an error makes Pub/Sub redeliver it.
+type NotificationHandlerFunc func(ctx context.Context, notification *DeveloperNotificationV2, message *PubSubMessage) error
+
+// NotificationHandler is an http.Handler for the push subscription of the Real-time developer notifications topic.
+// It authenticates the push request, unwraps the Pub/Sub envelope and passes the notification to Handle.
+// The response is 204 when Handle succeeds, which acknowledges the message, 401 or 403 when the request is not
+// authenticated and 500 when Handle fails or is nil. Pub/Sub redelivers on any non 2xx status.
+// A malformed message would never be handled, so it is acknowledged with 204 and reported to ErrorLog.
+// https://developer.android.com/google/play/billing/rtdn-reference
+// https://cloud.google.com/pubsub/docs/authenticate-push-subscriptions
+type NotificationHandler struct {
+	// Audience is the audience configured on the push subscription. Pub/Sub uses the push endpoint URL when none is configured.
+	Audience string
+	// ServiceAccount is the email of the service account configured on the push subscription.
+	ServiceAccount string
+	// KeySet resolves the keys of the push tokens, a JWKS of GoogleCertsURL shared by the handlers when nil.
+	KeySet KeySet
+	// Handle is called with each notification.
+	Handle NotificationHandlerFunc
+	// Now returns the current time to check the token expiration, time.Now when nil.
+	Now func() time.Time
+	// ErrorLog receives the malformed messages and the errors of Handle, when not nil.
+	ErrorLog func(err error)
+}
+
+// NewNotificationHandler returns a NotificationHandler which authenticates the push tokens with Google's JWKS.
+// It returns ErrPushAudienceMissing when audience is empty, as any token would then be accepted.
+func NewNotificationHandler(audience, serviceAccount string, handle NotificationHandlerFunc) (*NotificationHandler, error) {
+	if audience == "" {
+		return nil, ErrPushAudienceMissing
+	}
+	return &NotificationHandler{
+		Audience:       audience,
+		ServiceAccount: serviceAccount,
+		Handle:         handle,
+	}, nil
+}
+
+var defaultKeySet = NewJWKS(GoogleCertsURL)
+
+// pushClaims are the claims of the OIDC token of a push request.
+type pushClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	jwt.RegisteredClaims
+}
+
+// Authenticate verifies the OIDC bearer token of the push request.
+func (h *NotificationHandler) Authenticate(r *http.Request) error {
+	if h.Audience == "" {
+		return ErrPushAudienceMissing
+	}
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || bearer == "" {
+		return ErrPushTokenMissing
+	}
+	keySet := h.KeySet
+	if keySet == nil {
+		keySet = defaultKeySet
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithAudience(h.Audience),
+		jwt.WithExpirationRequired(),
+	}
+	if h.Now != nil {
+		opts = append(opts, jwt.WithTimeFunc(h.Now))
+	}
+
+	claims := &pushClaims{}
+	_, err := jwt.ParseWithClaims(bearer, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keySet.Key(r.Context(), kid)
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPushTokenInvalid, err)
+	}
+	if claims.Issuer != "accounts.google.com" && claims.Issuer != "https://accounts.google.com" {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrPushTokenInvalid, claims.Issuer)
+	}
+	if claims.Email != h.ServiceAccount || !claims.EmailVerified {
+		return fmt.Errorf("%w: unexpected service account %q", ErrPushTokenInvalid, claims.Email)
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.Authenticate(r); err != nil {
+		switch {
+		case errors.Is(err, ErrPushAudienceMissing):
+			h.logError(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		case errors.Is(err, ErrPushTokenMissing):
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+		return
+	}
+
+	var push PushRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&push); err != nil {
+		h.logError(fmt.Errorf("%w: %v", ErrPushMessageInvalid, err))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	notification := &DeveloperNotificationV2{}
+	if err := json.Unmarshal(push.Message.Data, notification); err != nil {
+		h.logError(fmt.Errorf("%w: message %s: %v", ErrPushMessageInvalid, push.Message.MessageID, err))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if h.Handle == nil {
+		h.logError(fmt.Errorf("%w: message %s", ErrPushHandleMissing, push.Message.MessageID))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.Handle(r.Context(), notification, &push.Message); err != nil {
+		h.logError(fmt.Errorf("playstore: message %s: %w", push.Message.MessageID, err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *NotificationHandler) logError(err error) {
+	if h.ErrorLog != nil {
+		h.ErrorLog(err)
+	}
+}
@@ -0,0 +1,210 @@
+package playstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testAudience       = "https://example.com/rtdn"
+	testServiceAccount = "pubsub@example.iam.gserviceaccount.com"
+)
+
+func signPushToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func validPushClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testAudience,
+		"email":          testServiceAccount,
+		"email_verified": true,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func pushBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"data":        base64.StdEncoding.EncodeToString(data),
+			"messageId":   "136969346945",
+			"publishTime": "2024-01-01T00:00:00Z",
+		},
+		"subscription": "projects/myproject/subscriptions/mysubscription",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+var errDBDown = errors.New("db down")
+
+func TestNotificationHandler(t *testing.T) {
+	t.Parallel()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification := []byte(`{"version":"1.0","packageName":"com.example.app","eventTimeMillis":"1503349566168","subscriptionNotification":{"version":"1.0","notificationType":4,"purchaseToken":"token","subscriptionId":"monthly"}}`)
+
+	tests := []struct {
+		name       string
+		token      string
+		body       []byte
+		handleErr  error
+		noHandle   bool
+		wantStatus int
+		wantCalled bool
+		wantLogged error
+	}{
+		{name: "valid", token: signPushToken(t, key, "kid", validPushClaims()), body: pushBody(t, notification), wantStatus: http.StatusNoContent, wantCalled: true},
+		{name: "handler error", token: signPushToken(t, key, "kid", validPushClaims()), body: pushBody(t, notification), handleErr: errDBDown, wantStatus: http.StatusInternalServerError, wantCalled: true, wantLogged: errDBDown},
+		{name: "nil handle", token: signPushToken(t, key, "kid", validPushClaims()), body: pushBody(t, notification), noHandle: true, wantStatus: http.StatusInternalServerError, wantLogged: ErrPushHandleMissing},
+		{name: "missing token", body: pushBody(t, notification), wantStatus: http.StatusUnauthorized},
+		{name: "unknown key", token: signPushToken(t, other, "other", validPushClaims()), body: pushBody(t, notification), wantStatus: http.StatusForbidden},
+		{name: "wrong signature", token: signPushToken(t, other, "kid", validPushClaims()), body: pushBody(t, notification), wantStatus: http.StatusForbidden},
+		{name: "wrong audience", token: func() string {
+			claims := validPushClaims()
+			claims["aud"] = "https://example.com/other"
+			return signPushToken(t, key, "kid", claims)
+		}(), body: pushBody(t, notification), wantStatus: http.StatusForbidden},
+		{name: "wrong service account", token: func() string {
+			claims := validPushClaims()
+			claims["email"] = "attacker@example.com"
+			return signPushToken(t, key, "kid", claims)
+		}(), body: pushBody(t, notification), wantStatus: http.StatusForbidden},
+		{name: "expired", token: func() string {
+			claims := validPushClaims()
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+			return signPushToken(t, key, "kid", claims)
+		}(), body: pushBody(t, notification), wantStatus: http.StatusForbidden},
+		{name: "malformed envelope", token: signPushToken(t, key, "kid", validPushClaims()), body: []byte("{"), wantStatus: http.StatusNoContent, wantLogged: ErrPushMessageInvalid},
+		{name: "malformed data", token: signPushToken(t, key, "kid", validPushClaims()), body: pushBody(t, []byte("not json")), wantStatus: http.StatusNoContent, wantLogged: ErrPushMessageInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			var logged error
+			h, err := NewNotificationHandler(testAudience, testServiceAccount, func(ctx context.Context, n *DeveloperNotificationV2, m *PubSubMessage) error {
+				called = true
+				if n.SubscriptionNotification == nil || n.SubscriptionNotification.NotificationType != SubscriptionNotificationTypePurchased {
+					t.Errorf("unexpected notification %+v", n)
+				}
+				if m.MessageID != "136969346945" {
+					t.Errorf("got %v, want 136969346945", m.MessageID)
+				}
+				return tt.handleErr
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.KeySet = StaticKeySet{"kid": &key.PublicKey}
+			h.ErrorLog = func(err error) { logged = err }
+			if tt.noHandle {
+				// as a handler built as a literal without Handle
+				h.Handle = nil
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/rtdn", bytes.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("got called %v, want %v", called, tt.wantCalled)
+			}
+			if !errors.Is(logged, tt.wantLogged) || (logged == nil) != (tt.wantLogged == nil) {
+				t.Errorf("got logged %v, want %v", logged, tt.wantLogged)
+			}
+		})
+	}
+}
+
+func TestNewNotificationHandler_MissingAudience(t *testing.T) {
+	t.Parallel()
+	if _, err := NewNotificationHandler("", testServiceAccount, nil); !errors.Is(err, ErrPushAudienceMissing) {
+		t.Errorf("got %v, want %v", err, ErrPushAudienceMissing)
+	}
+
+	h := &NotificationHandler{ServiceAccount: testServiceAccount}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rtdn", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	t.Parallel()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "kid",
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	jwks := NewJWKS(srv.URL)
+	got, err := jwks.Key(context.Background(), "kid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PublicKey.Equal(got) {
+		t.Error("unexpected key")
+	}
+	if _, err := jwks.Key(context.Background(), "kid"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwks.Key(context.Background(), "unknown"); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("got %v, want %v", err, ErrUnknownKeyID)
+	}
+	if fetches != 1 {
+		t.Errorf("got %d fetches, want 1", fetches)
+	}
+}
@@ -0,0 +1,162 @@
+package playstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// APIFamily is a group of Google Play Developer API methods sharing a quota.
+type APIFamily string
+
+const (
+	// APIVoidedPurchases is the family of VoidedPurchases.
+	APIVoidedPurchases APIFamily = "voidedpurchases"
+	// APIOrders is the family of GetOrder and BatchGetOrder.
+	APIOrders APIFamily = "orders"
+	// APIPurchases is the family of the product and subscription purchase methods, such as VerifySubscriptionV2.
+	APIPurchases APIFamily = "purchases"
+)
+
+// RateLimit allows Requests requests during any Per period.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// VoidedPurchasesRateLimits are the quotas of the Voided Purchases API, applied by default.
+// The daily quota is approximated by a bucket refilling over 24 hours instead of resetting at midnight Pacific Time.
+// https://developer.android.com/google/play/billing/getting-ready#voided-purchases-quotas
+var VoidedPurchasesRateLimits = []RateLimit{
+	{Requests: 30, Per: 30 * time.Second},
+	{Requests: 6000, Per: 24 * time.Hour},
+}
+
+// bucket is a token bucket of a RateLimit.
+type bucket struct {
+	capacity float64
+	// rate is the number of tokens refilled per second
+	rate    float64
+	tokens  float64
+	updated time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+	}
+	b.updated = now
+}
+
+// RateLimiter is a token bucket limiter enforcing several rate limits at once. It is safe for concurrent use.
+type RateLimiter struct {
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets []*bucket
+}
+
+// NewRateLimiter returns a RateLimiter allowing a burst of each limit, starting full.
+func NewRateLimiter(limits ...RateLimit) *RateLimiter {
+	l := &RateLimiter{}
+	now := l.now()
+	for _, limit := range limits {
+		l.buckets = append(l.buckets, &bucket{
+			capacity: float64(limit.Requests),
+			rate:     float64(limit.Requests) / limit.Per.Seconds(),
+			tokens:   float64(limit.Requests),
+			updated:  now,
+		})
+	}
+	return l
+}
+
+func (l *RateLimiter) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+// Remaining returns the number of requests which can be sent now without waiting.
+func (l *RateLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	remaining := math.MaxInt
+	for _, b := range l.buckets {
+		b.refill(now)
+		remaining = min(remaining, int(b.tokens))
+	}
+	return remaining
+}
+
+// reserve takes a token of each bucket, or returns how long to wait until all of them have one.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var wait time.Duration
+	for _, b := range l.buckets {
+		b.refill(now)
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/b.rate*float64(time.Second)))
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, b := range l.buckets {
+		b.tokens--
+	}
+	return 0
+}
+
+// Wait blocks until a request can be sent. It returns an error without waiting when the context
+// would expire before, and when the context is canceled while waiting.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait := l.reserve()
+		if wait == 0 {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && l.now().Add(wait).After(deadline) {
+			return fmt.Errorf("playstore: rate limit wait of %v exceeds the deadline: %w", wait, context.DeadlineExceeded)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// SetRateLimiter replaces the limiter of an API family, nil removes it. Call it before using the client.
+func (c *Client) SetRateLimiter(family APIFamily, limiter *RateLimiter) {
+	if c.limiters == nil {
+		c.limiters = map[APIFamily]*RateLimiter{}
+	}
+	if limiter == nil {
+		delete(c.limiters, family)
+		return
+	}
+	c.limiters[family] = limiter
+}
+
+// RateLimiter returns the limiter of an API family, e.g. to read its Remaining budget. It is nil when the family is not limited.
+func (c *Client) RateLimiter(family APIFamily) *RateLimiter {
+	return c.limiters[family]
+}
+
+// wait blocks until the limiter of family allows a request.
+func (c *Client) wait(ctx context.Context, family APIFamily) error {
+	if l := c.limiters[family]; l != nil {
+		return l.Wait(ctx)
+	}
+	return nil
+}
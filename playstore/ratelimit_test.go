@@ -0,0 +1,103 @@
+package playstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(RateLimit{Requests: 3, Per: 3 * time.Second}, RateLimit{Requests: 5, Per: time.Hour})
+	l.Now = func() time.Time { return now }
+
+	if got := l.Remaining(); got != 3 {
+		t.Errorf("got %d remaining, want 3", got)
+	}
+	for i := 0; i < 3; i++ {
+		if wait := l.reserve(); wait != 0 {
+			t.Fatalf("got wait %v on request %d, want 0", wait, i)
+		}
+	}
+	if got := l.reserve(); got != time.Second {
+		t.Errorf("got wait %v, want 1s", got)
+	}
+
+	now = now.Add(2 * time.Second)
+	if got := l.Remaining(); got != 2 {
+		t.Errorf("got %d remaining, want 2", got)
+	}
+	for i := 0; i < 2; i++ {
+		if wait := l.reserve(); wait != 0 {
+			t.Fatalf("got wait %v on request %d, want 0", wait, i)
+		}
+	}
+
+	// the hourly bucket is exhausted even though the per second one refills
+	now = now.Add(10 * time.Second)
+	if got := l.Remaining(); got != 0 {
+		t.Errorf("got %d remaining, want 0", got)
+	}
+	if got := l.reserve(); got < 10*time.Minute {
+		t.Errorf("got wait %v, want the hourly refill", got)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Parallel()
+	l := NewRateLimiter(RateLimit{Requests: 1, Per: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("got %v, want the second request to wait", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	l = NewRateLimiter(RateLimit{Requests: 1, Per: time.Hour})
+	_ = l.Wait(ctx)
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestClient_RateLimiter(t *testing.T) {
+	t.Parallel()
+	c := newClient(nil)
+	if c.RateLimiter(APIVoidedPurchases) == nil {
+		t.Fatal("voided purchases are not limited by default")
+	}
+	if got := c.RateLimiter(APIVoidedPurchases).Remaining(); got != 30 {
+		t.Errorf("got %d remaining, want 30", got)
+	}
+
+	limiter := NewRateLimiter(RateLimit{Requests: 1, Per: time.Hour})
+	c.SetRateLimiter(APIOrders, limiter)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.wait(ctx, APIOrders); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrder(ctx, "com.example.app", "GPA.1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	c.SetRateLimiter(APIVoidedPurchases, nil)
+	if c.RateLimiter(APIVoidedPurchases) != nil {
+		t.Error("voided purchases limiter is not removed")
+	}
+}
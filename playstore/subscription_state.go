@@ -0,0 +1,194 @@
+package playstore
+
+import (
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.subscriptionsv2#subscriptionstate
+const (
+	SubscriptionStateUnspecified             = "SUBSCRIPTION_STATE_UNSPECIFIED"
+	SubscriptionStatePending                 = "SUBSCRIPTION_STATE_PENDING"
+	SubscriptionStateActive                  = "SUBSCRIPTION_STATE_ACTIVE"
+	SubscriptionStatePaused                  = "SUBSCRIPTION_STATE_PAUSED"
+	SubscriptionStateInGracePeriod           = "SUBSCRIPTION_STATE_IN_GRACE_PERIOD"
+	SubscriptionStateOnHold                  = "SUBSCRIPTION_STATE_ON_HOLD"
+	SubscriptionStateCanceled                = "SUBSCRIPTION_STATE_CANCELED"
+	SubscriptionStateExpired                 = "SUBSCRIPTION_STATE_EXPIRED"
+	SubscriptionStatePendingPurchaseCanceled = "SUBSCRIPTION_STATE_PENDING_PURCHASE_CANCELED"
+)
+
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.subscriptionsv2#acknowledgementstate
+const (
+	AcknowledgementStatePending      = "ACKNOWLEDGEMENT_STATE_PENDING"
+	AcknowledgementStateAcknowledged = "ACKNOWLEDGEMENT_STATE_ACKNOWLEDGED"
+)
+
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.subscriptionsv2#pricechangestate
+const (
+	PriceChangeStateOutstanding = "OUTSTANDING"
+	PriceChangeStateConfirmed   = "CONFIRMED"
+	PriceChangeStateApplied     = "APPLIED"
+)
+
+// SubscriptionEvent is the next event expected for a line item of a subscription.
+type SubscriptionEvent string
+
+// list of SubscriptionEvent
+const (
+	// SubscriptionEventNone means nothing is expected, the line item ended.
+	SubscriptionEventNone SubscriptionEvent = ""
+	// SubscriptionEventPurchaseCompletion means the pending payment completes or is canceled.
+	SubscriptionEventPurchaseCompletion SubscriptionEvent = "purchase_completion"
+	// SubscriptionEventRenewal means the line item renews at its expiry time.
+	SubscriptionEventRenewal SubscriptionEvent = "renewal"
+	// SubscriptionEventReplacement means the line item is replaced by ReplacementProductID at its expiry time.
+	SubscriptionEventReplacement SubscriptionEvent = "replacement"
+	// SubscriptionEventExpiration means the line item expires at its expiry time, it is canceled or prepaid.
+	SubscriptionEventExpiration SubscriptionEvent = "expiration"
+	// SubscriptionEventAccountHold means the line item goes on hold at the end of the grace period unless the payment is recovered.
+	SubscriptionEventAccountHold SubscriptionEvent = "account_hold"
+	// SubscriptionEventRecovery means the payment is recovered, or the subscription expires at the end of the account hold.
+	SubscriptionEventRecovery SubscriptionEvent = "recovery"
+	// SubscriptionEventResume means the paused subscription resumes.
+	SubscriptionEventResume SubscriptionEvent = "resume"
+)
+
+// SubscriptionItemState is the effective entitlement of a line item of a subscription.
+// Time fields are the zero time when the purchase does not provide them.
+type SubscriptionItemState struct {
+	ProductID  string
+	BasePlanID string
+	OfferID    string
+	Prepaid    bool
+	AutoRenew  bool
+
+	// Entitled tells whether the user has access to the line item at the time of the evaluation.
+	Entitled   bool
+	State      entitlement.State
+	ExpiryTime time.Time
+
+	NextEvent     SubscriptionEvent
+	NextEventTime time.Time
+
+	// ReplacementProductID is the product replacing this one at the next renewal, empty without a deferred replacement.
+	ReplacementProductID string
+	// PriceChangeTime is when the new price of a price change not applied yet is charged.
+	PriceChangeTime time.Time
+	// PriceChangeConsentPending is true when the user has not accepted the price increase yet.
+	PriceChangeConsentPending bool
+	// TopUpAfter is when a prepaid plan can be extended.
+	TopUpAfter time.Time
+}
+
+// SubscriptionStatus is the effective entitlement of a subscription purchase, see EvaluateSubscription.
+type SubscriptionStatus struct {
+	SubscriptionState string
+	// AcknowledgementPending is true when the purchase must still be acknowledged, Google Play refunds it after three days otherwise.
+	AcknowledgementPending bool
+	LineItems              []SubscriptionItemState
+}
+
+// Entitled reports whether any line item grants access.
+func (s *SubscriptionStatus) Entitled() bool {
+	for _, item := range s.LineItems {
+		if item.Entitled {
+			return true
+		}
+	}
+	return false
+}
+
+// BasePlan returns the line item of the base plan of the product.
+func (s *SubscriptionStatus) BasePlan(productID, basePlanID string) (SubscriptionItemState, bool) {
+	for _, item := range s.LineItems {
+		if item.ProductID == productID && item.BasePlanID == basePlanID {
+			return item, true
+		}
+	}
+	return SubscriptionItemState{}, false
+}
+
+// EvaluateSubscription works out the entitlement and the next expected event of each line item of a subscription at now.
+// A line item past its expiry time grants no access even when the subscription state does, which happens
+// when the items of a multi-line-item purchase expire at different times.
+// https://developer.android.com/google/play/billing/lifecycle/subscriptions
+func EvaluateSubscription(purchase *androidpublisher.SubscriptionPurchaseV2, now time.Time) *SubscriptionStatus {
+	status := &SubscriptionStatus{
+		SubscriptionState:      purchase.SubscriptionState,
+		AcknowledgementPending: purchase.AcknowledgementState == AcknowledgementStatePending,
+		LineItems:              make([]SubscriptionItemState, 0, len(purchase.LineItems)),
+	}
+	for _, item := range purchase.LineItems {
+		status.LineItems = append(status.LineItems, evaluateLineItem(purchase, item, now))
+	}
+	return status
+}
+
+func evaluateLineItem(purchase *androidpublisher.SubscriptionPurchaseV2, item *androidpublisher.SubscriptionPurchaseLineItem, now time.Time) SubscriptionItemState {
+	s := SubscriptionItemState{
+		ProductID:  item.ProductId,
+		Prepaid:    item.PrepaidPlan != nil,
+		State:      subscriptionStateToEntitlement(purchase.SubscriptionState),
+		ExpiryTime: parseRFC3339(item.ExpiryTime),
+	}
+	if details := item.OfferDetails; details != nil {
+		s.BasePlanID = details.BasePlanId
+		s.OfferID = details.OfferId
+	}
+	if plan := item.AutoRenewingPlan; plan != nil {
+		s.AutoRenew = plan.AutoRenewEnabled
+		if change := plan.PriceChangeDetails; change != nil && change.PriceChangeState != PriceChangeStateApplied {
+			s.PriceChangeTime = parseRFC3339(change.ExpectedNewPriceChargeTime)
+			s.PriceChangeConsentPending = change.PriceChangeState == PriceChangeStateOutstanding
+		}
+	}
+	if plan := item.PrepaidPlan; plan != nil {
+		s.TopUpAfter = parseRFC3339(plan.AllowExtendAfterTime)
+	}
+	if replacement := item.DeferredItemReplacement; replacement != nil {
+		s.ReplacementProductID = replacement.ProductId
+	}
+
+	expired := !s.ExpiryTime.IsZero() && !s.ExpiryTime.After(now)
+	switch purchase.SubscriptionState {
+	case SubscriptionStatePending:
+		s.NextEvent = SubscriptionEventPurchaseCompletion
+	case SubscriptionStateActive, SubscriptionStateCanceled:
+		if expired {
+			s.State = entitlement.StateExpired
+			break
+		}
+		s.Entitled = true
+		s.NextEventTime = s.ExpiryTime
+		switch {
+		case s.Prepaid || !s.AutoRenew || purchase.SubscriptionState == SubscriptionStateCanceled:
+			s.NextEvent = SubscriptionEventExpiration
+		case s.ReplacementProductID != "":
+			s.NextEvent = SubscriptionEventReplacement
+		default:
+			s.NextEvent = SubscriptionEventRenewal
+		}
+	case SubscriptionStateInGracePeriod:
+		// the expiry time is the end of the grace period
+		if expired {
+			s.State = entitlement.StateBillingRetry
+			s.NextEvent = SubscriptionEventRecovery
+			break
+		}
+		s.Entitled = true
+		s.NextEvent = SubscriptionEventAccountHold
+		s.NextEventTime = s.ExpiryTime
+	case SubscriptionStateOnHold:
+		s.NextEvent = SubscriptionEventRecovery
+	case SubscriptionStatePaused:
+		s.NextEvent = SubscriptionEventResume
+		if paused := purchase.PausedStateContext; paused != nil {
+			s.NextEventTime = parseRFC3339(paused.AutoResumeTime)
+		}
+	}
+	return s
+}
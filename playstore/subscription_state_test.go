@@ -0,0 +1,135 @@
+package playstore
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+
+	"github.com/awa/go-iap/entitlement"
+)
+
+func TestEvaluateSubscription(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	future := "2024-07-01T00:00:00Z"
+	futureTime := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	past := "2024-05-01T00:00:00Z"
+	autoRenewing := func(expiry string) *androidpublisher.SubscriptionPurchaseLineItem {
+		return &androidpublisher.SubscriptionPurchaseLineItem{
+			ProductId:        "premium",
+			ExpiryTime:       expiry,
+			AutoRenewingPlan: &androidpublisher.AutoRenewingPlan{AutoRenewEnabled: true},
+			OfferDetails:     &androidpublisher.OfferDetails{BasePlanId: "monthly"},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		state         string
+		item          *androidpublisher.SubscriptionPurchaseLineItem
+		paused        *androidpublisher.PausedStateContext
+		wantEntitled  bool
+		wantState     entitlement.State
+		wantEvent     SubscriptionEvent
+		wantEventTime time.Time
+	}{
+		{name: "pending", state: SubscriptionStatePending, item: autoRenewing(""), wantState: entitlement.StatePending, wantEvent: SubscriptionEventPurchaseCompletion},
+		{name: "active", state: SubscriptionStateActive, item: autoRenewing(future), wantEntitled: true, wantState: entitlement.StateActive, wantEvent: SubscriptionEventRenewal, wantEventTime: futureTime},
+		{name: "active past expiry", state: SubscriptionStateActive, item: autoRenewing(past), wantState: entitlement.StateExpired},
+		{name: "paused", state: SubscriptionStatePaused, item: autoRenewing(past), paused: &androidpublisher.PausedStateContext{AutoResumeTime: future}, wantState: entitlement.StatePaused, wantEvent: SubscriptionEventResume, wantEventTime: futureTime},
+		{name: "in grace period", state: SubscriptionStateInGracePeriod, item: autoRenewing(future), wantEntitled: true, wantState: entitlement.StateGracePeriod, wantEvent: SubscriptionEventAccountHold, wantEventTime: futureTime},
+		{name: "grace period ended", state: SubscriptionStateInGracePeriod, item: autoRenewing(past), wantState: entitlement.StateBillingRetry, wantEvent: SubscriptionEventRecovery},
+		{name: "on hold", state: SubscriptionStateOnHold, item: autoRenewing(past), wantState: entitlement.StateBillingRetry, wantEvent: SubscriptionEventRecovery},
+		{name: "canceled", state: SubscriptionStateCanceled, item: autoRenewing(future), wantEntitled: true, wantState: entitlement.StateActive, wantEvent: SubscriptionEventExpiration, wantEventTime: futureTime},
+		{name: "canceled past expiry", state: SubscriptionStateCanceled, item: autoRenewing(past), wantState: entitlement.StateExpired},
+		{name: "expired", state: SubscriptionStateExpired, item: autoRenewing(past), wantState: entitlement.StateExpired},
+		{name: "pending purchase canceled", state: SubscriptionStatePendingPurchaseCanceled, item: autoRenewing(""), wantState: entitlement.StateExpired},
+		{name: "unspecified", state: SubscriptionStateUnspecified, item: autoRenewing(future), wantState: entitlement.StateExpired},
+		{name: "auto renew disabled", state: SubscriptionStateActive, item: &androidpublisher.SubscriptionPurchaseLineItem{
+			ProductId:        "premium",
+			ExpiryTime:       future,
+			AutoRenewingPlan: &androidpublisher.AutoRenewingPlan{},
+		}, wantEntitled: true, wantState: entitlement.StateActive, wantEvent: SubscriptionEventExpiration, wantEventTime: futureTime},
+		{name: "deferred replacement", state: SubscriptionStateActive, item: &androidpublisher.SubscriptionPurchaseLineItem{
+			ProductId:               "premium",
+			ExpiryTime:              future,
+			AutoRenewingPlan:        &androidpublisher.AutoRenewingPlan{AutoRenewEnabled: true},
+			DeferredItemReplacement: &androidpublisher.DeferredItemReplacement{ProductId: "basic"},
+		}, wantEntitled: true, wantState: entitlement.StateActive, wantEvent: SubscriptionEventReplacement, wantEventTime: futureTime},
+		{name: "prepaid", state: SubscriptionStateActive, item: &androidpublisher.SubscriptionPurchaseLineItem{
+			ProductId:   "premium",
+			ExpiryTime:  future,
+			PrepaidPlan: &androidpublisher.PrepaidPlan{AllowExtendAfterTime: "2024-06-24T00:00:00Z"},
+		}, wantEntitled: true, wantState: entitlement.StateActive, wantEvent: SubscriptionEventExpiration, wantEventTime: futureTime},
+		{name: "prepaid expired", state: SubscriptionStateExpired, item: &androidpublisher.SubscriptionPurchaseLineItem{
+			ProductId:   "premium",
+			ExpiryTime:  past,
+			PrepaidPlan: &androidpublisher.PrepaidPlan{},
+		}, wantState: entitlement.StateExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := EvaluateSubscription(&androidpublisher.SubscriptionPurchaseV2{
+				SubscriptionState:  tt.state,
+				PausedStateContext: tt.paused,
+				LineItems:          []*androidpublisher.SubscriptionPurchaseLineItem{tt.item},
+			}, now)
+			got := status.LineItems[0]
+			if got.Entitled != tt.wantEntitled || status.Entitled() != tt.wantEntitled {
+				t.Errorf("got entitled %v, want %v", got.Entitled, tt.wantEntitled)
+			}
+			if got.State != tt.wantState {
+				t.Errorf("got state %v, want %v", got.State, tt.wantState)
+			}
+			if got.NextEvent != tt.wantEvent || !got.NextEventTime.Equal(tt.wantEventTime) {
+				t.Errorf("got next event %v at %v, want %v at %v", got.NextEvent, got.NextEventTime, tt.wantEvent, tt.wantEventTime)
+			}
+		})
+	}
+}
+
+func TestEvaluateSubscription_LineItems(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	status := EvaluateSubscription(&androidpublisher.SubscriptionPurchaseV2{
+		SubscriptionState:    SubscriptionStateActive,
+		AcknowledgementState: AcknowledgementStatePending,
+		LineItems: []*androidpublisher.SubscriptionPurchaseLineItem{
+			{
+				ProductId:  "music",
+				ExpiryTime: "2024-07-01T00:00:00Z",
+				AutoRenewingPlan: &androidpublisher.AutoRenewingPlan{
+					AutoRenewEnabled: true,
+					PriceChangeDetails: &androidpublisher.SubscriptionItemPriceChangeDetails{
+						ExpectedNewPriceChargeTime: "2024-07-01T00:00:00Z",
+						PriceChangeState:           PriceChangeStateOutstanding,
+					},
+				},
+				OfferDetails: &androidpublisher.OfferDetails{BasePlanId: "monthly", OfferId: "intro"},
+			},
+			{
+				ProductId:        "video",
+				ExpiryTime:       "2024-05-15T00:00:00Z",
+				AutoRenewingPlan: &androidpublisher.AutoRenewingPlan{AutoRenewEnabled: true},
+				OfferDetails:     &androidpublisher.OfferDetails{BasePlanId: "monthly"},
+			},
+		},
+	}, now)
+
+	if !status.AcknowledgementPending || !status.Entitled() {
+		t.Errorf("got %+v, want an entitled purchase pending acknowledgement", status)
+	}
+	music, ok := status.BasePlan("music", "monthly")
+	if !ok || !music.Entitled || music.OfferID != "intro" || !music.PriceChangeConsentPending || music.PriceChangeTime.IsZero() {
+		t.Errorf("unexpected music line item %+v", music)
+	}
+	video, ok := status.BasePlan("video", "monthly")
+	if !ok || video.Entitled || video.State != entitlement.StateExpired {
+		t.Errorf("unexpected video line item %+v", video)
+	}
+	if _, ok := status.BasePlan("music", "yearly"); ok {
+		t.Error("got a line item for an unknown base plan")
+	}
+}
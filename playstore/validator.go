@@ -51,7 +51,16 @@ type IABMonetization interface {
 
 // The Client type implements VerifySubscription method
 type Client struct {
-	service *androidpublisher.Service
+	service  *androidpublisher.Service
+	limiters map[APIFamily]*RateLimiter
+}
+
+// newClient returns a Client of service, limiting the voided purchases queries to their quotas.
+func newClient(service *androidpublisher.Service) *Client {
+	return &Client{
+		service:  service,
+		limiters: map[APIFamily]*RateLimiter{APIVoidedPurchases: NewRateLimiter(VoidedPurchasesRateLimits...)},
+	}
 }
 
 // New returns http client which includes the credentials to access androidpublisher API.
@@ -77,7 +86,7 @@ func New(jsonKey []byte) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{service}, err
+	return newClient(service), err
 }
 
 // NewWithClient returns http client which includes the custom http client.
@@ -98,7 +107,17 @@ func NewWithClient(jsonKey []byte, cli *http.Client) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{service}, err
+	return newClient(service), err
+}
+
+// NewWithOptions returns a client built from the given client options, such as option.WithEndpoint and option.WithHTTPClient.
+// The options are responsible for the credentials.
+func NewWithOptions(opts ...option.ClientOption) (*Client, error) {
+	service, err := androidpublisher.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(service), nil
 }
 
 // NewDefaultTokenSourceClient returns a client that authenticates using Google Application Default Credentials.
@@ -113,7 +132,7 @@ func NewDefaultTokenSourceClient() (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{service}, nil
+	return newClient(service), nil
 }
 
 // AcknowledgeSubscription acknowledges a subscription purchase.
@@ -124,6 +143,9 @@ func (c *Client) AcknowledgeSubscription(
 	token string,
 	req *androidpublisher.SubscriptionPurchasesAcknowledgeRequest,
 ) error {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Acknowledge(packageName, subscriptionID, token, req).Context(ctx).Do()
 
@@ -138,6 +160,9 @@ func (c *Client) VerifySubscription(
 	subscriptionID string,
 	token string,
 ) (*androidpublisher.SubscriptionPurchase, error) {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	result, err := ps.Get(packageName, subscriptionID, token).Context(ctx).Do()
 
@@ -150,6 +175,9 @@ func (c *Client) VerifySubscriptionV2(
 	packageName string,
 	token string,
 ) (*androidpublisher.SubscriptionPurchaseV2, error) {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsv2Service(c.service)
 	result, err := ps.Get(packageName, token).Context(ctx).Do()
 
@@ -163,6 +191,9 @@ func (c *Client) RevokeSubscriptionV2(
 	token string,
 	req *androidpublisher.RevokeSubscriptionPurchaseRequest,
 ) (*androidpublisher.RevokeSubscriptionPurchaseResponse, error) {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsv2Service(c.service)
 	result, err := ps.Revoke(packageName, token, req).Context(ctx).Do()
 
@@ -176,6 +207,9 @@ func (c *Client) VerifyProduct(
 	productID string,
 	token string,
 ) (*androidpublisher.ProductPurchase, error) {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewPurchasesProductsService(c.service)
 	result, err := ps.Get(packageName, productID, token).Context(ctx).Do()
 
@@ -183,6 +217,9 @@ func (c *Client) VerifyProduct(
 }
 
 func (c *Client) AcknowledgeProduct(ctx context.Context, packageName, productID, token, developerPayload string) error {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return err
+	}
 	ps := androidpublisher.NewPurchasesProductsService(c.service)
 	acknowledgeRequest := &androidpublisher.ProductPurchasesAcknowledgeRequest{DeveloperPayload: developerPayload}
 	err := ps.Acknowledge(packageName, productID, token, acknowledgeRequest).Context(ctx).Do()
@@ -191,6 +228,9 @@ func (c *Client) AcknowledgeProduct(ctx context.Context, packageName, productID,
 }
 
 func (c *Client) ConsumeProduct(ctx context.Context, packageName, productID, token string) error {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return err
+	}
 	ps := androidpublisher.NewPurchasesProductsService(c.service)
 	err := ps.Consume(packageName, productID, token).Context(ctx).Do()
 
@@ -199,6 +239,9 @@ func (c *Client) ConsumeProduct(ctx context.Context, packageName, productID, tok
 
 // CancelSubscription cancels a user's subscription purchase.
 func (c *Client) CancelSubscription(ctx context.Context, packageName string, subscriptionID string, token string) error {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Cancel(packageName, subscriptionID, token).Context(ctx).Do()
 
@@ -208,6 +251,9 @@ func (c *Client) CancelSubscription(ctx context.Context, packageName string, sub
 // RefundSubscription refunds a user's subscription purchase, but the subscription remains valid
 // until its expiration time and it will continue to recur.
 func (c *Client) RefundSubscription(ctx context.Context, packageName string, subscriptionID string, token string) error {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Refund(packageName, subscriptionID, token).Context(ctx).Do()
 
@@ -217,6 +263,9 @@ func (c *Client) RefundSubscription(ctx context.Context, packageName string, sub
 // RevokeSubscription refunds and immediately revokes a user's subscription purchase.
 // Access to the subscription will be terminated immediately and it will stop recurring.
 func (c *Client) RevokeSubscription(ctx context.Context, packageName string, subscriptionID string, token string) error {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Revoke(packageName, subscriptionID, token).Context(ctx).Do()
 
@@ -227,6 +276,9 @@ func (c *Client) RevokeSubscription(ctx context.Context, packageName string, sub
 // Access to the subscription will be terminated immediately and it will stop recurring.
 func (c *Client) DeferSubscription(ctx context.Context, packageName string, subscriptionID string, token string,
 	req *androidpublisher.SubscriptionPurchasesDeferRequest) (*androidpublisher.SubscriptionPurchasesDeferResponse, error) {
+	if err := c.wait(ctx, APIPurchases); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	result, err := ps.Defer(packageName, subscriptionID, token, req).Context(ctx).Do()
 
@@ -268,6 +320,7 @@ const (
 // Quotas:
 // 1. 6000 queries per day. (The day begins and ends at midnight Pacific Time.)
 // 2. 30 queries during any 30-second period.
+// The client waits for these quotas by default, see SetRateLimiter.
 func (c *Client) VoidedPurchases(
 	ctx context.Context,
 	packageName string,
@@ -278,6 +331,9 @@ func (c *Client) VoidedPurchases(
 	startIndex int64,
 	productType VoidedPurchaseType,
 ) (*androidpublisher.VoidedPurchasesListResponse, error) {
+	if err := c.wait(ctx, APIVoidedPurchases); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewPurchasesVoidedpurchasesService(c.service)
 
 	call := ps.List(packageName).StartTime(startTime).EndTime(endTime).Type(int64(productType)).MaxResults(maxResult).Context(ctx)
@@ -331,6 +387,9 @@ func (c *Client) GetOrder(ctx context.Context,
 	packageName string,
 	orderId string,
 ) (*androidpublisher.Order, error) {
+	if err := c.wait(ctx, APIOrders); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewOrdersService(c.service)
 	result, err := ps.Get(packageName, orderId).Context(ctx).Do()
 
@@ -342,6 +401,9 @@ func (c *Client) BatchGetOrder(ctx context.Context,
 	packageName string,
 	orderIds ...string,
 ) (*androidpublisher.BatchGetOrdersResponse, error) {
+	if err := c.wait(ctx, APIOrders); err != nil {
+		return nil, err
+	}
 	ps := androidpublisher.NewOrdersService(c.service)
 	result, err := ps.Batchget(packageName).OrderIds(orderIds...).Context(ctx).Do()
 